@@ -17,10 +17,16 @@ type Config struct {
 	ApiKey            string              `yaml:"apiKey"`
 	TargetProjects    []string            `yaml:"targetProjects"`
 	ExcludeProjects   []string            `yaml:"excludeProjects"`
+	TargetOrgs        []string            `yaml:"targetOrgs"`
+	ExcludeOrgs       []string            `yaml:"excludeOrgs"`
 	GitDetails        GitDetails          `yaml:"gitDetails"`
+	GitRoutes         []GitRoute          `yaml:"gitRoutes"`
 	FileStoreConfig   FileStoreConfig     `yaml:"fileStoreConfig"`
 	TargetServices    []map[string]string `yaml:"targetServices"`
 	ExcludeServices   []map[string]string `yaml:"excludeServices"`
+	Retry             RetryPolicy         `yaml:"retry"`
+	Filters           EntityFilters       `yaml:"filters"`
+	SecretScan        SecretScanConfig    `yaml:"secretScan"`
 }
 
 type GitDetails struct {
@@ -38,6 +44,57 @@ type FileStoreConfig struct {
 	Branch        string `yaml:"branch"`
 	RepositoryURL string `yaml:"url"`
 	ConnectorRef  string `yaml:"connector_ref" json:"connector_ref"`
+	// Workspace is the local folder the file store repo is built in
+	Workspace string    `yaml:"workspace"`
+	Auth      GitAuth   `yaml:"auth"`
+	Author    GitAuthor `yaml:"author"`
+	// DownloadConcurrency is the number of files downloaded in parallel
+	DownloadConcurrency int `yaml:"download_concurrency"`
+	// Metadata writes the metadata of the files of every scope next to them
+	Metadata bool `yaml:"metadata"`
+	// Scope selects the file stores that are migrated and Repos the repos
+	// their files are pushed to instead of this one
+	Scope FileStoreScope    `yaml:"scope"`
+	Repos []FileStoreTarget `yaml:"repos"`
+}
+
+// DefaultDownloadConcurrency is the number of files downloaded in parallel
+// when none is configured.
+const DefaultDownloadConcurrency = 8
+
+// DefaultFileStoreWorkspace is the folder the file store repo is built in
+// when no workspace is configured.
+const DefaultFileStoreWorkspace = "filestore"
+
+// WorkspaceDir returns the folder the file store repo is built in.
+func (c FileStoreConfig) WorkspaceDir() string {
+	if len(c.Workspace) == 0 {
+		return DefaultFileStoreWorkspace
+	}
+	return c.Workspace
+}
+
+// DownloadWorkers returns the number of files downloaded in parallel.
+func (c FileStoreConfig) DownloadWorkers() int {
+	if c.DownloadConcurrency <= 0 {
+		return DefaultDownloadConcurrency
+	}
+	return c.DownloadConcurrency
+}
+
+// GitAuth holds the credentials the file store repo is pushed with, a token
+// for HTTPS URLs or an SSH key for SSH URLs. Username defaults to git.
+type GitAuth struct {
+	Username         string `yaml:"username"`
+	Token            string `yaml:"token"`
+	SSHKeyPath       string `yaml:"ssh_key_path"`
+	SSHKeyPassphrase string `yaml:"ssh_key_passphrase"`
+}
+
+// GitAuthor is the author of the file store commits.
+type GitAuthor struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
 }
 
 func (c *Config) ReadConfig(filepath string) *Config {
@@ -2,9 +2,11 @@ package harness
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	resty "github.com/go-resty/resty/v2"
@@ -26,6 +28,30 @@ func GetAccountIDFromAPIKey(apiKey string) string {
 	return accountId
 }
 
+// v1ScopePath returns the path of a v1 resource at the account, org or
+// project scope.
+func v1ScopePath(org, project, resource string) string {
+	switch {
+	case len(org) == 0:
+		return "/v1/" + resource
+	case len(project) == 0:
+		return "/v1/orgs/{org}/" + resource
+	default:
+		return "/v1/orgs/{org}/projects/{project}/" + resource
+	}
+}
+
+// omitEmptyScope drops the org and project identifiers of account and org
+// level requests.
+func omitEmptyScope(params map[string]string) map[string]string {
+	for _, key := range []string{"orgIdentifier", "projectIdentifier"} {
+		if len(params[key]) == 0 {
+			delete(params, key)
+		}
+	}
+	return params
+}
+
 func GetServiceManifestStoreType(connectorType string) string {
 	if connectorType == "Gitlab" {
 		return "GitLab"
@@ -34,105 +60,251 @@ func GetServiceManifestStoreType(connectorType string) string {
 }
 
 func (api *APIRequest) GetAllProjects(account string) (Projects, error) {
+	projects := Projects{}
+	content, err := paginate("projects", func(page int) ([]ProjectsContent, bool, error) {
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetQueryParams(map[string]string{
+				"accountIdentifier": account,
+				"hasModule":         "true",
+				"pageIndex":         strconv.Itoa(page),
+				"pageSize":          "500",
+			}).
+			Get(api.BaseURL + "/ng/api/projects")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
+		err = json.Unmarshal(resp.Body(), &projects)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return projects.Data.Content, morePages(projects.Data.PageIndex, projects.Data.TotalPages), nil
+	})
+	projects.Data.Content = content
+
+	return projects, err
+}
+
+func (api *APIRequest) GetAllPipelines(account, org, project string) (Pipelines, error) {
+	pipelines := Pipelines{}
+	content, err := paginate("pipelines", func(page int) ([]PipelineContent, bool, error) {
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"filterType": "PipelineSetup"}`).
+			SetQueryParams(map[string]string{
+				"accountIdentifier": account,
+				"orgIdentifier":     org,
+				"projectIdentifier": project,
+				"page":              strconv.Itoa(page),
+				"size":              "1000",
+			}).
+			Post(api.BaseURL + "/pipeline/api/pipelines/list")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
+		err = json.Unmarshal(resp.Body(), &pipelines)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return pipelines.Data.Content, !pipelines.Data.Last, nil
+	})
+	pipelines.Data.Content = content
+
+	return pipelines, err
+}
+
+// GetPipelineYAML returns the YAML of a single pipeline.
+func (api *APIRequest) GetPipelineYAML(account, org, project, identifier string) (string, error) {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetQueryParams(map[string]string{
 			"accountIdentifier": account,
-			"hasModule":         "true",
-			"pageSize":          "500",
+			"orgIdentifier":     org,
+			"projectIdentifier": project,
 		}).
-		Get(api.BaseURL + "/ng/api/projects")
+		SetPathParam("identifier", identifier).
+		Get(api.BaseURL + "/pipeline/api/pipelines/{identifier}")
 	if err != nil {
-		return Projects{}, err
+		return "", err
 	}
-	projects := Projects{}
-	err = json.Unmarshal(resp.Body(), &projects)
+	if resp.StatusCode() != 200 {
+		return "", responseError(resp)
+	}
+
+	result := struct {
+		Data struct {
+			YAMLPipeline string `json:"yamlPipeline"`
+		} `json:"data"`
+	}{}
+	err = json.Unmarshal(resp.Body(), &result)
 	if err != nil {
-		return Projects{}, err
+		return "", err
 	}
 
-	return projects, nil
+	return result.Data.YAMLPipeline, nil
 }
 
-func (api *APIRequest) GetAllPipelines(account, org, project string) (Pipelines, error) {
+// UpdatePipelineYAML replaces the YAML of an inline pipeline.
+func (api *APIRequest) UpdatePipelineYAML(account, org, project, identifier, yaml string) error {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetBody(`{"filterType": "PipelineSetup"}`).
+		SetHeader("Content-Type", "application/yaml").
 		SetQueryParams(map[string]string{
 			"accountIdentifier": account,
 			"orgIdentifier":     org,
 			"projectIdentifier": project,
-			"size":              "1000",
 		}).
-		Post(api.BaseURL + "/pipeline/api/pipelines/list")
+		SetPathParam("identifier", identifier).
+		SetBody(yaml).
+		Put(api.BaseURL + "/pipeline/api/pipelines/v2/{identifier}")
 	if err != nil {
-		return Pipelines{}, err
+		return err
 	}
-	pipelines := Pipelines{}
-	err = json.Unmarshal(resp.Body(), &pipelines)
-	if err != nil {
-		return Pipelines{}, err
+	if resp.StatusCode() != 200 {
+		return responseError(resp)
 	}
 
-	return pipelines, nil
+	return nil
 }
 
 func (api *APIRequest) GetInputsets(account, org, project, pipeline string) ([]*InputsetContent, error) {
+	return paginate("input sets", func(page int) ([]*InputsetContent, bool, error) {
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(map[string]string{
+				"routingId":          account,
+				"accountIdentifier":  account,
+				"orgIdentifier":      org,
+				"projectIdentifier":  project,
+				"pipelineIdentifier": pipeline,
+				"pageIndex":          strconv.Itoa(page),
+				"pageSize":           "1000",
+			}).
+			Get(api.BaseURL + "/gateway/pipeline/api/inputSets")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
+		result := ListInputsetResponse{}
+		err = json.Unmarshal(resp.Body(), &result)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return result.Data.Content, morePages(result.Data.PageIndex, result.Data.TotalPages), nil
+	})
+}
+
+func (api *APIRequest) GetAllTemplates(account, org, project string) (Templates, error) {
+	const limit = 1000
+	return paginate("templates", func(page int) ([]Template, bool, error) {
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Harness-Account", account).
+			SetQueryParams(omitEmptyScope(map[string]string{
+				"orgIdentifier":     org,
+				"projectIdentifier": project,
+				"page":              strconv.Itoa(page),
+				"limit":             strconv.Itoa(limit),
+			})).
+			SetPathParam("org", org).
+			SetPathParam("project", project).
+			Get(api.BaseURL + v1ScopePath(org, project, "templates"))
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
+		templates := Templates{}
+		err = json.Unmarshal(resp.Body(), &templates)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return templates, moreV1Pages(resp, page, limit, len(templates)), nil
+	})
+}
+
+// GetTemplateYAML returns the YAML of a single template version.
+func (api *APIRequest) GetTemplateYAML(account, org, project, identifier, versionLabel string) (string, error) {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"routingId":          account,
-			"accountIdentifier":  account,
-			"orgIdentifier":      org,
-			"projectIdentifier":  project,
-			"pipelineIdentifier": pipeline,
-			"size":               "1000",
-		}).
-		Get(api.BaseURL + "/gateway/pipeline/api/inputSets")
+		SetQueryParams(omitEmptyScope(map[string]string{
+			"accountIdentifier": account,
+			"orgIdentifier":     org,
+			"projectIdentifier": project,
+			"versionLabel":      versionLabel,
+		})).
+		SetPathParam("identifier", identifier).
+		Get(api.BaseURL + "/template/api/templates/{identifier}")
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if resp.StatusCode() != 200 {
+		return "", responseError(resp)
 	}
 
-	result := ListInputsetResponse{}
+	result := struct {
+		Data struct {
+			YAML string `json:"yaml"`
+		} `json:"data"`
+	}{}
 	err = json.Unmarshal(resp.Body(), &result)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return result.Data.Content, nil
+	return result.Data.YAML, nil
 }
 
-func (api *APIRequest) GetAllTemplates(account, org, project string) (Templates, error) {
+// UpdateTemplateYAML replaces the YAML of an inline template version.
+func (api *APIRequest) UpdateTemplateYAML(account, org, project, identifier, versionLabel, yaml string) error {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Harness-Account", account).
-		SetQueryParams(map[string]string{
+		SetHeader("Content-Type", "application/yaml").
+		SetQueryParams(omitEmptyScope(map[string]string{
+			"accountIdentifier": account,
 			"orgIdentifier":     org,
 			"projectIdentifier": project,
-			"limit":             "1000",
-		}).
-		Get(api.BaseURL + fmt.Sprintf("/v1/orgs/%s/projects/%s/templates", org, project))
+		})).
+		SetPathParam("identifier", identifier).
+		SetPathParam("versionLabel", versionLabel).
+		SetBody(yaml).
+		Put(api.BaseURL + "/template/api/templates/update/{identifier}/{versionLabel}")
 	if err != nil {
-		return Templates{}, err
+		return err
 	}
-	templates := Templates{}
-	err = json.Unmarshal(resp.Body(), &templates)
-	if err != nil {
-		return Templates{}, err
+	if resp.StatusCode() != 200 {
+		return responseError(resp)
 	}
 
-	return templates, nil
+	return nil
 }
 
 func (p *PipelineContent) MovePipelineToRemote(api *APIRequest, c Config, org, project string) (string, error) {
+	return p.MovePipeline(api, c, org, project, InlineToRemote)
+}
+
+func (p *PipelineContent) MovePipeline(api *APIRequest, c Config, org, project string, moveType MoveConfigType) (string, error) {
 	type RequestBody struct {
-		GitDetails              GitDetails `json:"git_details"`
-		PipelineIdentifier      string     `json:"pipeline_identifier"`
-		MoveConfigOperationType string     `json:"move_config_operation_type"`
+		GitDetails              GitDetails     `json:"git_details"`
+		PipelineIdentifier      string         `json:"pipeline_identifier"`
+		MoveConfigOperationType MoveConfigType `json:"move_config_operation_type"`
 	}
 
 	resp, err := api.Client.R().
@@ -147,30 +319,34 @@ func (p *PipelineContent) MovePipelineToRemote(api *APIRequest, c Config, org, p
 		SetBody(RequestBody{
 			GitDetails:              c.GitDetails,
 			PipelineIdentifier:      p.Identifier,
-			MoveConfigOperationType: "INLINE_TO_REMOTE",
+			MoveConfigOperationType: moveType,
 		}).
-		Post(api.BaseURL + fmt.Sprintf("/v1/orgs/%s/projects/%s/pipelines/%s/move-config", org, project, p.Identifier))
+		SetPathParam("org", org).
+		SetPathParam("project", project).
+		SetPathParam("pipeline", p.Identifier).
+		Post(api.BaseURL + "/v1/orgs/{org}/projects/{project}/pipelines/{pipeline}/move-config")
 
+	if err != nil {
+		return "", err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return "", err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return "", fmt.Errorf(errMsg)
+		return "", moveConfigError(resp)
 	}
 
-	return string(resp.Body()), err
+	return string(resp.Body()), nil
 }
 
 func (t *Template) MoveTemplateToRemote(api *APIRequest, c Config) (string, error) {
+	return t.MoveTemplate(api, c, InlineToRemote)
+}
+
+func (t *Template) MoveTemplate(api *APIRequest, c Config, moveType MoveConfigType) (string, error) {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetHeader("Harness-Account", c.AccountIdentifier).
 		SetHeader("Content-Type", "application/json").
 		SetPathParam("templateIdentifier", t.Identifier).
-		SetQueryParams(map[string]string{
+		SetQueryParams(omitEmptyScope(map[string]string{
 			"accountIdentifier": c.AccountIdentifier,
 			"projectIdentifier": t.Project,
 			"orgIdentifier":     t.Org,
@@ -181,28 +357,29 @@ func (t *Template) MoveTemplateToRemote(api *APIRequest, c Config) (string, erro
 			"isNewBranch":       "false",
 			"filePath":          c.GitDetails.FilePath,
 			"commitMsg":         c.GitDetails.CommitMessage,
-			"moveConfigType":    "INLINE_TO_REMOTE",
-		}).
+			"moveConfigType":    string(moveType),
+		})).
 		Post(api.BaseURL + "/template/api/templates/move-config/{templateIdentifier}")
 
+	if err != nil {
+		return "", err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return "", err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return "", fmt.Errorf(errMsg)
+		return "", moveConfigError(resp)
 	}
 
-	return string(resp.Body()), err
+	return string(resp.Body()), nil
 }
 
 func (s *ServiceClass) MoveServiceToRemote(api *APIRequest, c Config) (string, bool, error) {
+	return s.MoveService(api, c, InlineToRemote)
+}
+
+func (s *ServiceClass) MoveService(api *APIRequest, c Config, moveType MoveConfigType) (string, bool, error) {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetPathParam("serviceIdentifier", s.Identifier).
-		SetQueryParams(map[string]string{
+		SetQueryParams(omitEmptyScope(map[string]string{
 			"accountIdentifier": c.AccountIdentifier,
 			"projectIdentifier": s.Project,
 			"orgIdentifier":     s.Org,
@@ -212,34 +389,34 @@ func (s *ServiceClass) MoveServiceToRemote(api *APIRequest, c Config) (string, b
 			"isNewBranch":       "false",
 			"filePath":          c.GitDetails.FilePath,
 			"commitMsg":         c.GitDetails.CommitMessage,
-			"moveConfigType":    "INLINE_TO_REMOTE",
-		}).
+			"moveConfigType":    string(moveType),
+		})).
 		Post(api.BaseURL + "/gateway/ng/api/servicesV2/move-config/{serviceIdentifier}")
 
+	if err != nil {
+		return "", false, err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return "", false, err
-		}
-
+		err = moveConfigError(resp)
 		// WHEN A SERVICE IS ALREADY REMOTE WE DON'T REPORT IT AS ERROR
-		if len(ar.ResponseMessages) == 1 && strings.Contains(ar.ResponseMessages[0].Message, "is already remote") {
+		if errors.Is(err, ErrAlreadyRemote) {
 			return "", true, nil
 		}
-
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return "", false, fmt.Errorf(errMsg)
+		return "", false, err
 	}
 
-	return string(resp.Body()), false, err
+	return string(resp.Body()), false, nil
 }
 
 func (e *EnvironmentClass) MoveEnvironmentToRemote(api *APIRequest, c Config) error {
+	return e.MoveEnvironment(api, c, InlineToRemote)
+}
+
+func (e *EnvironmentClass) MoveEnvironment(api *APIRequest, c Config, moveType MoveConfigType) error {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetPathParam("environmentIdentifier", e.Identifier).
-		SetQueryParams(map[string]string{
+		SetQueryParams(omitEmptyScope(map[string]string{
 			"accountIdentifier": c.AccountIdentifier,
 			"projectIdentifier": e.ProjectIdentifier,
 			"orgIdentifier":     e.OrgIdentifier,
@@ -250,24 +427,25 @@ func (e *EnvironmentClass) MoveEnvironmentToRemote(api *APIRequest, c Config) er
 			"isHarnessCodeRepo": "false",
 			"filePath":          c.GitDetails.FilePath,
 			"commitMsg":         c.GitDetails.CommitMessage,
-			"moveConfigType":    "INLINE_TO_REMOTE",
-		}).
+			"moveConfigType":    string(moveType),
+		})).
 		Post(api.BaseURL + "/gateway/ng/api/environmentsV2/move-config/{environmentIdentifier}")
 
+	if err != nil {
+		return err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return moveConfigError(resp)
 	}
 
-	return err
+	return nil
 }
 
 func (is *InputsetContent) MoveInputsetToRemote(api *APIRequest, c Config, project, org string) error {
+	return is.MoveInputset(api, c, project, org, InlineToRemote)
+}
+
+func (is *InputsetContent) MoveInputset(api *APIRequest, c Config, project, org string, moveType MoveConfigType) error {
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetPathParam("identifier", is.Identifier).
@@ -285,29 +463,30 @@ func (is *InputsetContent) MoveInputsetToRemote(api *APIRequest, c Config, proje
 			"isHarnessCodeRepo":  "false",
 			"filePath":           c.GitDetails.FilePath,
 			"commitMsg":          c.GitDetails.CommitMessage,
-			"moveConfigType":     "INLINE_TO_REMOTE",
+			"moveConfigType":     string(moveType),
 		}).
 		Post(api.BaseURL + "/gateway/pipeline/api/inputSets/move-config/{identifier}")
 
+	if err != nil {
+		return err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return moveConfigError(resp)
 	}
 
-	return err
+	return nil
 }
 
 func (i *Infrastructure) MoveInfrastructureToRemote(api *APIRequest, c Config, envId string) error {
+	return i.MoveInfrastructure(api, c, envId, InlineToRemote)
+}
+
+func (i *Infrastructure) MoveInfrastructure(api *APIRequest, c Config, envId string, moveType MoveConfigType) error {
 
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
 		SetPathParam("infraIdentifier", i.Identifier).
-		SetQueryParams(map[string]string{
+		SetQueryParams(omitEmptyScope(map[string]string{
 			"accountIdentifier":     c.AccountIdentifier,
 			"projectIdentifier":     i.ProjectIdentifier,
 			"orgIdentifier":         i.OrgIdentifier,
@@ -319,24 +498,25 @@ func (i *Infrastructure) MoveInfrastructureToRemote(api *APIRequest, c Config, e
 			"isHarnessCodeRepo":     "false",
 			"filePath":              c.GitDetails.FilePath,
 			"commitMsg":             c.GitDetails.CommitMessage,
-			"moveConfigType":        "INLINE_TO_REMOTE",
-		}).
+			"moveConfigType":        string(moveType),
+		})).
 		Post(api.BaseURL + "/gateway/ng/api/infrastructures/move-config/{infraIdentifier}")
 
+	if err != nil {
+		return err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return moveConfigError(resp)
 	}
 
-	return err
+	return nil
 }
 
 func (ov *OverridesV2Content) MoveToRemote(api *APIRequest, c Config) error {
+	return ov.Move(api, c, InlineToRemote)
+}
+
+func (ov *OverridesV2Content) Move(api *APIRequest, c Config, moveType MoveConfigType) error {
 
 	params := map[string]string{
 		"accountIdentifier":    c.AccountIdentifier,
@@ -349,7 +529,7 @@ func (ov *OverridesV2Content) MoveToRemote(api *APIRequest, c Config) error {
 		"isHarnessCodeRepo":    "false",
 		"filePath":             c.GitDetails.FilePath,
 		"commitMsg":            c.GitDetails.CommitMessage,
-		"moveConfigType":       "INLINE_TO_REMOTE",
+		"moveConfigType":       string(moveType),
 		"serviceOverridesType": string(ov.Type),
 		"identifier":           ov.Identifier,
 	}
@@ -366,174 +546,152 @@ func (ov *OverridesV2Content) MoveToRemote(api *APIRequest, c Config) error {
 
 	resp, err := api.Client.R().
 		SetHeader("x-api-key", api.APIKey).
-		SetQueryParams(params).
+		SetQueryParams(omitEmptyScope(params)).
 		Post(api.BaseURL + "/gateway/ng/api/serviceOverrides/move-config")
 
+	if err != nil {
+		return err
+	}
 	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
-		if err != nil {
-			return err
-		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return moveConfigError(resp)
 	}
 
-	return err
+	return nil
 }
 
-func (api *APIRequest) GetAllOrgs(account string) (Organizations, error) {
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Harness-Account", account).
-		SetQueryParams(map[string]string{
-			"limit": "1000",
-		}).
-		Get(api.BaseURL + "/v1/orgs")
-	if err != nil {
-		return Organizations{}, err
+// moveConfigError is the error of a move-config call Harness did not accept.
+// A move that was already applied, by this run when a retried call reached the
+// server the first time or by anyone else, is reported as ErrAlreadyRemote.
+func moveConfigError(resp *resty.Response) error {
+	ar := ApiResponse{}
+	if err := json.Unmarshal(resp.Body(), &ar); err != nil {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode())
+	}
+	messages := []string{ar.Message}
+	for _, m := range ar.ResponseMessages {
+		messages = append(messages, m.Message)
+	}
+	for _, m := range messages {
+		if strings.Contains(strings.ToLower(m), "already remote") {
+			return ErrAlreadyRemote
+		}
 	}
+	return newAPIError(resp.StatusCode(), ar)
+}
 
-	organizations := Organizations{}
-	err = json.Unmarshal(resp.Body(), &organizations)
-	if err != nil {
-		return Organizations{}, err
-	}
+func (api *APIRequest) GetAllOrgs(account string) (Organizations, error) {
+	const limit = 1000
+	return paginate("organizations", func(page int) ([]Organization, bool, error) {
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Harness-Account", account).
+			SetQueryParams(map[string]string{
+				"page":  strconv.Itoa(page),
+				"limit": strconv.Itoa(limit),
+			}).
+			Get(api.BaseURL + "/v1/orgs")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
+
+		organizations := Organizations{}
+		err = json.Unmarshal(resp.Body(), &organizations)
+		if err != nil {
+			return nil, false, err
+		}
 
-	return organizations, nil
+		return organizations, moreV1Pages(resp, page, limit, len(organizations)), nil
+	})
 }
 
 func (api *APIRequest) GetAllAccountFiles(account string) ([]FileStoreContent, error) {
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"accountIdentifier": account,
-			"pageSize":          "2000",
-		}).
-		Get(api.BaseURL + "/ng/api/file-store")
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	fileStore := FileStore{}
-	err = json.Unmarshal(resp.Body(), &fileStore)
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	return fileStore.Data.Content, nil
+	return api.listFiles(map[string]string{
+		"accountIdentifier": account,
+	})
 }
 
 func (api *APIRequest) GetAllOrgFiles(account, org string) ([]FileStoreContent, error) {
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"accountIdentifier": account,
-			"orgIdentifier":     org,
-			"pageSize":          "2000",
-		}).
-		Get(api.BaseURL + "/ng/api/file-store")
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	fileStore := FileStore{}
-	err = json.Unmarshal(resp.Body(), &fileStore)
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	return fileStore.Data.Content, nil
+	return api.listFiles(map[string]string{
+		"accountIdentifier": account,
+		"orgIdentifier":     org,
+	})
 }
 
 func (api *APIRequest) GetAllProjectFiles(account, org, project string) ([]FileStoreContent, error) {
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"accountIdentifier": account,
-			"orgIdentifier":     org,
-			"projectIdentifier": project,
-			"pageSize":          "2000",
-		}).
-		Get(api.BaseURL + "/ng/api/file-store")
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	fileStore := FileStore{}
-	err = json.Unmarshal(resp.Body(), &fileStore)
-	if err != nil {
-		return []FileStoreContent{}, err
-	}
-
-	return fileStore.Data.Content, nil
+	return api.listFiles(map[string]string{
+		"accountIdentifier": account,
+		"orgIdentifier":     org,
+		"projectIdentifier": project,
+	})
 }
 
-func (f *FileStoreContent) DownloadFile(api *APIRequest, account, org, project, folder string) error {
-	var params map[string]string
-	if project == "" && org == "" {
-		params = map[string]string{
-			"accountIdentifier": account,
-		}
-	} else if org != "" && project == "" {
-		params = map[string]string{
-			"accountIdentifier": account,
-			"orgIdentifier":     org,
+func (api *APIRequest) listFiles(scope map[string]string) ([]FileStoreContent, error) {
+	return paginate("file store", func(page int) ([]FileStoreContent, bool, error) {
+		params := map[string]string{
+			"pageIndex": strconv.Itoa(page),
+			"pageSize":  "2000",
 		}
-	} else {
-		params = map[string]string{
-			"accountIdentifier": account,
-			"orgIdentifier":     org,
-			"projectIdentifier": project,
+		for k, v := range scope {
+			params[k] = v
 		}
-	}
-
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(params).
-		SetPathParam("id", f.Identifier).
-		Get(api.BaseURL + "/ng/api/file-store/files/{id}/download")
-	if err != nil {
-		return err
-	}
 
-	if resp.StatusCode() != 200 {
-		ar := ApiResponse{}
-		err = json.Unmarshal(resp.Body(), &ar)
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(params).
+			Get(api.BaseURL + "/ng/api/file-store")
 		if err != nil {
-			return err
+			return nil, false, err
 		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		if !strings.Contains(errMsg, "Downloading folder not supported") {
-			return fmt.Errorf(errMsg)
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
 		}
-	}
 
-	if !strings.Contains(f.Path, ".") {
-		return nil
-	}
+		fileStore := FileStore{}
+		err = json.Unmarshal(resp.Body(), &fileStore)
+		if err != nil {
+			return nil, false, err
+		}
 
-	err = os.MkdirAll(filepath.Dir("./filestore/filestore/"+folder+f.Path), 0755)
-	if err != nil {
-		return err
+		return fileStore.Data.Content, !fileStore.Data.Last && morePages(fileStore.Data.Number, fileStore.Data.TotalPages), nil
+	})
+}
+
+// DownloadFile writes the file to its file store path under dir, folders are
+// created empty. The body is streamed to a temporary file that replaces the
+// file once complete, so a failed download never leaves a partial file.
+func (f *FileStoreContent) DownloadFile(api *APIRequest, account, org, project, dir string) error {
+	target := filepath.Join(dir, f.Path)
+	if f.Type == FileStoreFolder {
+		return os.MkdirAll(target, 0755)
 	}
 
-	out, err := os.Create("./filestore/filestore/" + folder + f.Path)
+	tmp := target + ".download"
+	resp, err := api.Client.R().
+		SetHeader("x-api-key", api.APIKey).
+		SetQueryParams(omitEmptyScope(map[string]string{
+			"accountIdentifier": account,
+			"orgIdentifier":     org,
+			"projectIdentifier": project,
+		})).
+		SetPathParam("id", f.Identifier).
+		SetOutput(tmp).
+		Get(api.BaseURL + "/ng/api/file-store/files/{id}/download")
 	if err != nil {
+		os.Remove(tmp)
 		return err
 	}
-	defer out.Close()
 
-	_, err = out.Write(resp.Body())
-	if err != nil {
-		return err
+	if resp.StatusCode() != 200 {
+		body, _ := os.ReadFile(tmp)
+		os.Remove(tmp)
+		return bodyError(resp.StatusCode(), body)
 	}
 
-	return nil
+	return os.Rename(tmp, target)
 }
 
 func (api *APIRequest) GetConnector(account, org, project, identifier string) (ConnectorClass, error) {
@@ -569,37 +727,44 @@ func (api *APIRequest) GetConnector(account, org, project, identifier string) (C
 }
 
 func (api *APIRequest) GetServices(account, org, project string) ([]*ServiceClass, error) {
-	params := map[string]string{
-		"accountIdentifier": account,
-		"orgIdentifier":     org,
-		"projectIdentifier": project,
-		"limit":             "1000",
-	}
+	const limit = 1000
+	return paginate("services", func(page int) ([]*ServiceClass, bool, error) {
+		params := map[string]string{
+			"accountIdentifier": account,
+			"orgIdentifier":     org,
+			"projectIdentifier": project,
+			"page":              strconv.Itoa(page),
+			"limit":             strconv.Itoa(limit),
+		}
 
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Harness-Account", account).
-		SetQueryParams(params).
-		SetPathParam("org", org).
-		SetPathParam("project", project).
-		Get(api.BaseURL + "/v1/orgs/{org}/projects/{project}/services")
-	if err != nil {
-		return []*ServiceClass{}, err
-	}
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Harness-Account", account).
+			SetQueryParams(omitEmptyScope(params)).
+			SetPathParam("org", org).
+			SetPathParam("project", project).
+			Get(api.BaseURL + v1ScopePath(org, project, "services"))
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
-	service := []*Service{}
-	err = json.Unmarshal(resp.Body(), &service)
-	if err != nil {
-		return []*ServiceClass{}, err
-	}
+		service := []*Service{}
+		err = json.Unmarshal(resp.Body(), &service)
+		if err != nil {
+			return nil, false, err
+		}
 
-	serviceList := []*ServiceClass{}
-	for _, s := range service {
-		serviceList = append(serviceList, &s.Service)
-	}
+		serviceList := []*ServiceClass{}
+		for _, s := range service {
+			serviceList = append(serviceList, &s.Service)
+		}
 
-	return serviceList, nil
+		return serviceList, moreV1Pages(resp, page, limit, len(service)), nil
+	})
 }
 
 func (api *APIRequest) UpdateService(service ServiceRequest, account string) error {
@@ -622,78 +787,89 @@ func (api *APIRequest) UpdateService(service ServiceRequest, account string) err
 		if err != nil {
 			return err
 		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return newAPIError(resp.StatusCode(), ar)
 	}
 
 	return nil
 }
 
 func (api *APIRequest) GetEnvironments(account, org, project string) ([]*EnvironmentClass, error) {
-	params := map[string]string{
-		"accountIdentifier": account,
-	}
-	if org != "" {
-		params["orgIdentifier"] = org
-	}
-	if project != "" {
-		params["projectIdentifier"] = project
-	}
+	return paginate("environments", func(page int) ([]*EnvironmentClass, bool, error) {
+		params := map[string]string{
+			"accountIdentifier": account,
+			"page":              strconv.Itoa(page),
+			"size":              "1000",
+		}
+		if org != "" {
+			params["orgIdentifier"] = org
+		}
+		if project != "" {
+			params["projectIdentifier"] = project
+		}
 
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(params).
-		Get(api.BaseURL + "/ng/api/environmentsV2")
-	if err != nil {
-		return []*EnvironmentClass{}, err
-	}
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(params).
+			Get(api.BaseURL + "/ng/api/environmentsV2")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
-	env := Environment{}
-	err = json.Unmarshal(resp.Body(), &env)
-	if err != nil {
-		return []*EnvironmentClass{}, err
-	}
+		env := Environment{}
+		err = json.Unmarshal(resp.Body(), &env)
+		if err != nil {
+			return nil, false, err
+		}
 
-	envList := []*EnvironmentClass{}
-	for _, e := range env.Data.Content {
-		envList = append(envList, &e.Environment)
-	}
+		envList := []*EnvironmentClass{}
+		for _, e := range env.Data.Content {
+			envList = append(envList, &e.Environment)
+		}
 
-	return envList, nil
+		return envList, morePages(env.Data.PageIndex, env.Data.TotalPages), nil
+	})
 }
 
 func (api *APIRequest) GetInfrastructures(account, org, project, envId string) ([]*Infrastructure, error) {
+	return paginate("infrastructures", func(page int) ([]*Infrastructure, bool, error) {
+		params := map[string]string{
+			"accountIdentifier":     account,
+			"orgIdentifier":         org,
+			"projectIdentifier":     project,
+			"environmentIdentifier": envId,
+			"page":                  strconv.Itoa(page),
+			"size":                  "1000",
+		}
 
-	params := map[string]string{
-		"accountIdentifier":     account,
-		"orgIdentifier":         org,
-		"projectIdentifier":     project,
-		"environmentIdentifier": envId,
-		"limit":                 "1000",
-	}
-
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(params).
-		Get(api.BaseURL + "/ng/api/infrastructures")
-	if err != nil {
-		return []*Infrastructure{}, err
-	}
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(omitEmptyScope(params)).
+			Get(api.BaseURL + "/ng/api/infrastructures")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
-	result := InfraDefResult{}
-	err = json.Unmarshal(resp.Body(), &result)
-	if err != nil {
-		return []*Infrastructure{}, err
-	}
+		result := InfraDefResult{}
+		err = json.Unmarshal(resp.Body(), &result)
+		if err != nil {
+			return nil, false, err
+		}
 
-	infraList := []*Infrastructure{}
-	for _, content := range result.Data.Content {
-		infraList = append(infraList, &content.Infrastructure)
-	}
+		infraList := []*Infrastructure{}
+		for _, content := range result.Data.Content {
+			infraList = append(infraList, &content.Infrastructure)
+		}
 
-	return infraList, nil
+		return infraList, morePages(result.Data.PageIndex, result.Data.TotalPages), nil
+	})
 }
 
 func (api *APIRequest) UpdateEnvironment(env EnvironmentRequest, account string) error {
@@ -716,76 +892,83 @@ func (api *APIRequest) UpdateEnvironment(env EnvironmentRequest, account string)
 		if err != nil {
 			return err
 		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return newAPIError(resp.StatusCode(), ar)
 	}
 
 	return nil
 }
 
 func (api *APIRequest) GetServiceOverrides(environment, account, org, project string) ([]*ServiceOverrideContent, error) {
-	params := map[string]string{
-		"environmentIdentifier": environment,
-		"accountIdentifier":     account,
-		"orgIdentifier":         org,
-		"projectIdentifier":     project,
-	}
+	return paginate("service overrides", func(page int) ([]*ServiceOverrideContent, bool, error) {
+		params := map[string]string{
+			"environmentIdentifier": environment,
+			"accountIdentifier":     account,
+			"orgIdentifier":         org,
+			"projectIdentifier":     project,
+			"page":                  strconv.Itoa(page),
+			"size":                  "1000",
+		}
 
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(params).
-		SetPathParam("org", org).
-		SetPathParam("project", project).
-		Get(api.BaseURL + "/ng/api/environmentsV2/serviceOverrides")
-	if err != nil {
-		return []*ServiceOverrideContent{}, err
-	}
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(params).
+			SetPathParam("org", org).
+			SetPathParam("project", project).
+			Get(api.BaseURL + "/ng/api/environmentsV2/serviceOverrides")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
-	overrides := ServiceOverride{}
-	err = json.Unmarshal(resp.Body(), &overrides)
-	if err != nil {
-		return []*ServiceOverrideContent{}, err
-	}
+		overrides := ServiceOverride{}
+		err = json.Unmarshal(resp.Body(), &overrides)
+		if err != nil {
+			return nil, false, err
+		}
 
-	var overrideList []*ServiceOverrideContent
-	for _, o := range overrides.Data.Content {
-		overrideList = append(overrideList, &o)
-	}
+		var overrideList []*ServiceOverrideContent
+		for i := range overrides.Data.Content {
+			overrideList = append(overrideList, &overrides.Data.Content[i])
+		}
 
-	return overrideList, nil
+		return overrideList, morePages(overrides.Data.PageIndex, overrides.Data.TotalPages), nil
+	})
 }
 
 func (api *APIRequest) GetOverridesV2(account, org, project string, ovType OverridesV2Type) ([]OverridesV2Content, error) {
+	return paginate("overrides v2", func(page int) ([]OverridesV2Content, bool, error) {
+		params := map[string]string{
+			"accountIdentifier": account,
+			"orgIdentifier":     org,
+			"projectIdentifier": project,
+			"page":              strconv.Itoa(page),
+			"size":              "1000",
+			"type":              string(ovType),
+		}
 
-	params := map[string]string{
-		"accountIdentifier": account,
-		"orgIdentifier":     org,
-		"projectIdentifier": project,
-		"page":              "0",
-		"size":              "1000",
-		"type":              string(ovType),
-	}
-
-	resp, err := api.Client.R().
-		SetHeader("x-api-key", api.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(params).
-		Post(api.BaseURL + "/ng/api/serviceOverrides/v2/list")
-	if err != nil {
-		return nil, err
-	}
-
-	result := OverridesV2Response{}
-	err = json.Unmarshal(resp.Body(), &result)
-	if err != nil {
-		return nil, err
-	}
+		resp, err := api.Client.R().
+			SetHeader("x-api-key", api.APIKey).
+			SetHeader("Content-Type", "application/json").
+			SetQueryParams(omitEmptyScope(params)).
+			Post(api.BaseURL + "/ng/api/serviceOverrides/v2/list")
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, false, responseError(resp)
+		}
 
-	var list []OverridesV2Content
-	list = append(list, result.Data.Content...)
+		result := OverridesV2Response{}
+		err = json.Unmarshal(resp.Body(), &result)
+		if err != nil {
+			return nil, false, err
+		}
 
-	return list, nil
+		return result.Data.Content, morePages(result.Data.PageIndex, result.Data.TotalPages), nil
+	})
 }
 
 func (override *OverridesV2Content) UpdateOverrideV2(api *APIRequest, account string) error {
@@ -808,8 +991,7 @@ func (override *OverridesV2Content) UpdateOverrideV2(api *APIRequest, account st
 		if err != nil {
 			return err
 		}
-		errMsg := fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", ar.CorrelationID, ar.ResponseMessages)
-		return fmt.Errorf(errMsg)
+		return newAPIError(resp.StatusCode(), ar)
 	}
 
 	return nil
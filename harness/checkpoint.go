@@ -0,0 +1,218 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+type CheckpointStatus string
+
+// An entity is already-remote when its move found it remote, either moved by
+// a retried call of this run or by someone else since the plan was made. It
+// is verified and rolled back like the entities that are done.
+const (
+	CheckpointDone          CheckpointStatus = "done"
+	CheckpointAlreadyRemote CheckpointStatus = "already-remote"
+	CheckpointFailed        CheckpointStatus = "failed"
+	CheckpointRolledBack    CheckpointStatus = "rolled-back"
+)
+
+// CheckpointRecord is the outcome of a single entity, one record per line of
+// the checkpoint file. The entry is kept in full so that the entities can be
+// rolled back from the git location they were moved to. RefsRewritten marks
+// pipelines and templates whose file store references were rewritten in
+// Harness, rolling them back does not undo it.
+type CheckpointRecord struct {
+	PlanEntry
+	Key           string           `json:"key"`
+	Status        CheckpointStatus `json:"status"`
+	Error         string           `json:"error,omitempty"`
+	RefsRewritten bool             `json:"refsRewritten,omitempty"`
+	Time          time.Time        `json:"time"`
+}
+
+// Checkpoint persists the outcome of every entity as soon as it is known so
+// that an interrupted migration can be resumed. Records are appended and
+// synced one by one, a record torn by a crash is ignored on the next load.
+type Checkpoint struct {
+	mu      sync.Mutex
+	file    *os.File
+	records map[string]CheckpointRecord
+	order   []string
+}
+
+// ErrCheckpointExists is returned when a new run would start over the
+// checkpoint of a previous run that holds entities not rolled back yet, the
+// only record rollback can use.
+var ErrCheckpointExists = errors.New("checkpoint file holds the records of a previous run")
+
+// OpenCheckpoint opens the checkpoint file at path. When resume is set the
+// records of the previous run are loaded and new ones are appended, otherwise
+// the file is started over. A file that holds entities moved and not rolled
+// back is only started over when overwrite is set.
+func OpenCheckpoint(path string, resume, overwrite bool) (*Checkpoint, error) {
+	c := &Checkpoint{records: map[string]CheckpointRecord{}}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	torn := false
+	if !resume && !overwrite {
+		previous := &Checkpoint{records: map[string]CheckpointRecord{}}
+		if _, err := previous.load(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(previous.MovedEntries()) > 0 {
+			return nil, ErrCheckpointExists
+		}
+	}
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		var err error
+		torn, err = c.load(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+
+	if torn {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// load reads the records of a previous run. It returns whether the file ends
+// with a torn record that needs to be terminated before appending.
+func (c *Checkpoint) load(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		record := CheckpointRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		c.add(record)
+	}
+
+	return len(data) > 0 && data[len(data)-1] != '\n', nil
+}
+
+// add keeps the latest record of every entity, in the order the entities were
+// first recorded.
+func (c *Checkpoint) add(record CheckpointRecord) {
+	if _, ok := c.records[record.Key]; !ok {
+		c.order = append(c.order, record.Key)
+	}
+	c.records[record.Key] = record
+}
+
+// Completed reports whether a previous run already moved the entity.
+func (c *Checkpoint) Completed(e PlanEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	record, ok := c.records[e.Key()]
+	return ok && (record.Status == CheckpointDone || record.Status == CheckpointAlreadyRemote)
+}
+
+// Records returns the latest record of every entity, in the order the
+// entities were first recorded.
+func (c *Checkpoint) Records() []CheckpointRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := make([]CheckpointRecord, 0, len(c.order))
+	for _, key := range c.order {
+		records = append(records, c.records[key])
+	}
+	return records
+}
+
+// MovedEntries returns the entries of the entities recorded as moved, done or
+// already remote, in the order they were first recorded.
+func (c *Checkpoint) MovedEntries() []PlanEntry {
+	var entries []PlanEntry
+	for _, record := range c.Records() {
+		if record.Status == CheckpointDone || record.Status == CheckpointAlreadyRemote {
+			entries = append(entries, record.PlanEntry)
+		}
+	}
+	return entries
+}
+
+// Record stores the outcome of moving the entity and syncs it to disk.
+// refsRewritten is set when the file store references of the entity were
+// rewritten before the move, whatever its outcome. A rewrite recorded by a
+// previous run is kept.
+func (c *Checkpoint) Record(e PlanEntry, moveErr error, refsRewritten bool) error {
+	record := CheckpointRecord{PlanEntry: e, Status: CheckpointDone, RefsRewritten: refsRewritten || c.refsRewritten(e)}
+	switch {
+	case errors.Is(moveErr, ErrAlreadyRemote):
+		record.Status = CheckpointAlreadyRemote
+	case moveErr != nil:
+		record.Status = CheckpointFailed
+		record.Error = moveErr.Error()
+	}
+	return c.write(record)
+}
+
+// RecordRollback marks the entity as moved back inline, so that resuming the
+// migration moves it again. A failed rollback leaves the entity completed.
+func (c *Checkpoint) RecordRollback(e PlanEntry, rollbackErr error) error {
+	if rollbackErr != nil {
+		return nil
+	}
+	return c.write(CheckpointRecord{PlanEntry: e, Status: CheckpointRolledBack, RefsRewritten: c.refsRewritten(e)})
+}
+
+func (c *Checkpoint) refsRewritten(e PlanEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.records[e.Key()].RefsRewritten
+}
+
+func (c *Checkpoint) write(record CheckpointRecord) error {
+	record.Key = record.PlanEntry.Key()
+	record.Time = time.Now().UTC()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(record)
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// Len returns the number of entities with a recorded outcome.
+func (c *Checkpoint) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.records)
+}
+
+func (c *Checkpoint) Close() error {
+	return c.file.Close()
+}
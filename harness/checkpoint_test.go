@@ -0,0 +1,182 @@
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OpenCheckpoint_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	done := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build"}
+	remote := PlanEntry{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api"}
+	failed := PlanEntry{Type: TemplateEntity, Org: "default", Project: "p1", Identifier: "step", VersionLabel: "v1"}
+
+	c, err := OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Record(done, nil, false))
+	assert.NoError(t, c.Record(remote, ErrAlreadyRemote, false))
+	assert.NoError(t, c.Record(failed, errors.New("boom"), false))
+	assert.NoError(t, c.Close())
+
+	// Simulate a record torn by a crash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"type":"pipeline","key":"pi`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	c, err = OpenCheckpoint(path, true, false)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, 3, c.Len())
+	assert.True(t, c.Completed(done))
+	assert.True(t, c.Completed(remote))
+	assert.False(t, c.Completed(failed))
+
+	// Records appended after the torn one are still readable
+	assert.NoError(t, c.Record(failed, nil, false))
+	c, err = OpenCheckpoint(path, true, false)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.True(t, c.Completed(failed))
+
+	// Rolled back entities are moved again by the next run
+	assert.NoError(t, c.RecordRollback(done, nil))
+	assert.NoError(t, c.RecordRollback(remote, errors.New("boom")))
+	assert.False(t, c.Completed(done))
+	assert.True(t, c.Completed(remote))
+	records := c.Records()
+	assert.Len(t, records, 3)
+	assert.Equal(t, "build", records[0].Identifier)
+	assert.Equal(t, CheckpointRolledBack, records[0].Status)
+
+	fresh, err := OpenCheckpoint(filepath.Join(t.TempDir(), "missing.jsonl"), true, false)
+	assert.NoError(t, err)
+	defer fresh.Close()
+	assert.Equal(t, 0, fresh.Len())
+}
+
+func Test_OpenCheckpoint_Overwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	done := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build"}
+
+	c, err := OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Record(done, nil, false))
+	assert.NoError(t, c.Close())
+
+	// A new run keeps the records of the previous one
+	_, err = OpenCheckpoint(path, false, false)
+	assert.True(t, errors.Is(err, ErrCheckpointExists))
+	c, err = OpenCheckpoint(path, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.Len())
+	assert.NoError(t, c.Close())
+
+	c, err = OpenCheckpoint(path, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.Len())
+	assert.NoError(t, c.Close())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+
+	// Once nothing is left to roll back a new run starts the file over
+	c, err = OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Record(done, errors.New("conflict"), false))
+	assert.NoError(t, c.Close())
+	c, err = OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Record(done, nil, false))
+	assert.NoError(t, c.RecordRollback(done, nil))
+	assert.NoError(t, c.Close())
+	c, err = OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.Len())
+	assert.NoError(t, c.Close())
+}
+
+func Test_Checkpoint_RollbackReplayedMove(t *testing.T) {
+	var moveTypes []string
+	// The first move is applied but answered with 503, the retry finds the
+	// pipeline remote
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MoveType string `json:"move_config_operation_type"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		moveTypes = append(moveTypes, body.MoveType)
+		switch {
+		case len(moveTypes) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case body.MoveType == string(InlineToRemote):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"ERROR","message":"Pipeline build is already remote"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	api.SetRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, testLogger{t})
+	e := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build", GitDetails: GitDetails{FilePath: ".harness/build.yaml"}}
+
+	err := e.Move(&api, Config{AccountIdentifier: "acc"})
+	assert.True(t, errors.Is(err, ErrAlreadyRemote))
+	c, err2 := OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint.jsonl"), false, false)
+	assert.NoError(t, err2)
+	defer c.Close()
+	assert.NoError(t, c.Record(e, err, false))
+	report := NewReport()
+	report.Add(NewReportRecord(e, err, 0))
+
+	// Both the checkpoint and the report roll the pipeline back
+	assert.Equal(t, []string{e.Key()}, entryKeys(c.MovedEntries()))
+	assert.Equal(t, []string{e.Key()}, entryKeys(report.MovedEntries()))
+	for _, moved := range c.MovedEntries() {
+		assert.NoError(t, moved.Rollback(&api, Config{AccountIdentifier: "acc"}))
+	}
+	assert.Equal(t, []string{string(InlineToRemote), string(InlineToRemote), string(RemoteToInline)}, moveTypes)
+}
+
+func entryKeys(entries []PlanEntry) []string {
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key())
+	}
+	return keys
+}
+
+func Test_Checkpoint_RefsRewritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	pipeline := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build"}
+
+	c, err := OpenCheckpoint(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Record(pipeline, errors.New("boom"), true))
+	assert.NoError(t, c.Close())
+
+	// The rewrite is kept for a pipeline that stayed inline, by the run that
+	// moves it and by its rollback
+	c, err = OpenCheckpoint(path, true, false)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.True(t, c.Records()[0].RefsRewritten)
+	assert.Equal(t, CheckpointFailed, c.Records()[0].Status)
+	assert.NoError(t, c.Record(pipeline, nil, false))
+	assert.True(t, c.Records()[0].RefsRewritten)
+	assert.NoError(t, c.RecordRollback(pipeline, nil))
+	assert.Equal(t, CheckpointRolledBack, c.Records()[0].Status)
+	assert.True(t, c.Records()[0].RefsRewritten)
+}
@@ -5,3 +5,11 @@ const (
 	BaseURL      = "https://app.harness.io"
 	BaseURLProd3 = "https://app3.harness.io"
 )
+
+// MoveConfigType is the direction of a move-config call.
+type MoveConfigType string
+
+const (
+	InlineToRemote MoveConfigType = "INLINE_TO_REMOTE"
+	RemoteToInline MoveConfigType = "REMOTE_TO_INLINE"
+)
@@ -0,0 +1,239 @@
+package harness
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrDependencyFailed is returned for entities that were not moved because an
+// entity they depend on could not be moved.
+var ErrDependencyFailed = errors.New("dependency could not be moved")
+
+// EntityRef points at an entity referenced by another one. An empty
+// VersionLabel matches every version of a template.
+type EntityRef struct {
+	Type         EntityType `json:"type" yaml:"type"`
+	Org          string     `json:"org,omitempty" yaml:"org,omitempty"`
+	Project      string     `json:"project,omitempty" yaml:"project,omitempty"`
+	Identifier   string     `json:"identifier" yaml:"identifier"`
+	VersionLabel string     `json:"versionLabel,omitempty" yaml:"versionLabel,omitempty"`
+	Environment  string     `json:"environment,omitempty" yaml:"environment,omitempty"`
+}
+
+func (r EntityRef) matches(e PlanEntry) bool {
+	if len(r.VersionLabel) > 0 && r.VersionLabel != e.VersionLabel {
+		return false
+	}
+	if len(r.Environment) > 0 && r.Environment != e.Environment {
+		return false
+	}
+	return true
+}
+
+func (r EntityRef) indexKey() string {
+	return strings.Join([]string{string(r.Type), r.Org, r.Project, r.Identifier}, "|")
+}
+
+// Dependencies returns the entities the entry has to be moved after: the
+// references found in its YAML and the parents implied by its identifiers.
+func (e PlanEntry) Dependencies() []EntityRef {
+	deps := append([]EntityRef{}, e.References...)
+	switch e.Type {
+	case InputSetEntity:
+		deps = append(deps, EntityRef{Type: PipelineEntity, Org: e.Org, Project: e.Project, Identifier: e.Pipeline})
+	case InfrastructureEntity:
+		deps = append(deps, EntityRef{Type: EnvironmentEntity, Org: e.Org, Project: e.Project, Identifier: e.Environment})
+	case OverridesV2Entity:
+		if len(e.Environment) > 0 {
+			org, project, id := scopedRef(e.Environment, e.Org, e.Project)
+			deps = append(deps, EntityRef{Type: EnvironmentEntity, Org: org, Project: project, Identifier: id})
+			if len(e.Infrastructure) > 0 {
+				deps = append(deps, EntityRef{Type: InfrastructureEntity, Org: org, Project: project, Identifier: e.Infrastructure, Environment: id})
+			}
+		}
+		if len(e.Service) > 0 {
+			org, project, id := scopedRef(e.Service, e.Org, e.Project)
+			deps = append(deps, EntityRef{Type: ServiceEntity, Org: org, Project: project, Identifier: id})
+		}
+	}
+	return deps
+}
+
+// DependencyLevels groups the entries so that every entry is in a later level
+// than the entries it depends on. deps[i] holds the indices of the entries i
+// depends on; references to entities outside of entries are ignored. Entries
+// of a level are sorted by entity type and then by their position. Entries in
+// a dependency cycle are put in a last level and reported with cyclic.
+func DependencyLevels(entries []PlanEntry) (levels [][]int, deps [][]int, cyclic bool) {
+	index := map[string][]int{}
+	for i, e := range entries {
+		key := EntityRef{Type: e.Type, Org: e.Org, Project: e.Project, Identifier: e.Identifier}.indexKey()
+		index[key] = append(index[key], i)
+	}
+
+	deps = make([][]int, len(entries))
+	dependents := make([][]int, len(entries))
+	pending := make([]int, len(entries))
+	for i, e := range entries {
+		seen := map[int]bool{}
+		for _, ref := range e.Dependencies() {
+			for _, j := range index[ref.indexKey()] {
+				if j == i || seen[j] || !ref.matches(entries[j]) {
+					continue
+				}
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+				dependents[j] = append(dependents[j], i)
+				pending[i]++
+			}
+		}
+	}
+
+	byType := func(level []int) {
+		sort.SliceStable(level, func(a, b int) bool {
+			return dependencyRank[entries[level[a]].Type] < dependencyRank[entries[level[b]].Type]
+		})
+	}
+
+	var current []int
+	for i := range entries {
+		if pending[i] == 0 {
+			current = append(current, i)
+		}
+	}
+	placed := 0
+	for len(current) > 0 {
+		byType(current)
+		levels = append(levels, current)
+		placed += len(current)
+
+		var next []int
+		for _, i := range current {
+			for _, d := range dependents[i] {
+				pending[d]--
+				if pending[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		sort.Ints(next)
+		current = next
+	}
+
+	if placed < len(entries) {
+		var rest []int
+		for i := range entries {
+			if pending[i] > 0 {
+				rest = append(rest, i)
+			}
+		}
+		byType(rest)
+		levels = append(levels, rest)
+		cyclic = true
+	}
+
+	return levels, deps, cyclic
+}
+
+// DependencyOrder returns the entries in the order they can be moved in,
+// every entry after the entries it depends on.
+func DependencyOrder(entries []PlanEntry) []PlanEntry {
+	levels, _, _ := DependencyLevels(entries)
+	ordered := make([]PlanEntry, 0, len(entries))
+	for _, level := range levels {
+		for _, i := range level {
+			ordered = append(ordered, entries[i])
+		}
+	}
+	return ordered
+}
+
+// ExtractReferences collects the templates, services, environments and
+// infrastructure definitions referenced from an entity YAML. Runtime inputs
+// and expressions are skipped. org and project are the scope of the entity,
+// used to resolve references without an account. or org. prefix.
+func ExtractReferences(entityYAML, org, project string) []EntityRef {
+	var root interface{}
+	if err := yaml.Unmarshal([]byte(entityYAML), &root); err != nil {
+		return nil
+	}
+
+	var refs []EntityRef
+	seen := map[EntityRef]bool{}
+	add := func(ref EntityRef) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case map[interface{}]interface{}:
+			if ref, ok := refValue(n["templateRef"]); ok {
+				o, p, id := scopedRef(ref, org, project)
+				label, _ := refValue(n["versionLabel"])
+				add(EntityRef{Type: TemplateEntity, Org: o, Project: p, Identifier: id, VersionLabel: label})
+			}
+			if ref, ok := refValue(n["serviceRef"]); ok {
+				o, p, id := scopedRef(ref, org, project)
+				add(EntityRef{Type: ServiceEntity, Org: o, Project: p, Identifier: id})
+			}
+			if ref, ok := refValue(n["environmentRef"]); ok {
+				o, p, env := scopedRef(ref, org, project)
+				add(EntityRef{Type: EnvironmentEntity, Org: o, Project: p, Identifier: env})
+				infras, _ := n["infrastructureDefinitions"].([]interface{})
+				for _, infra := range infras {
+					m, _ := infra.(map[interface{}]interface{})
+					if id, ok := refValue(m["identifier"]); ok {
+						add(EntityRef{Type: InfrastructureEntity, Org: o, Project: p, Identifier: id, Environment: env})
+					}
+				}
+			}
+			for _, v := range n {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range n {
+				walk(v)
+			}
+		}
+	}
+	walk(root)
+
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.indexKey() != b.indexKey() {
+			return a.indexKey() < b.indexKey()
+		}
+		return a.VersionLabel+"|"+a.Environment < b.VersionLabel+"|"+b.Environment
+	})
+	return refs
+}
+
+// refValue returns fixed string values, runtime inputs and expressions
+// cannot be resolved while planning.
+func refValue(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) == 0 || strings.HasPrefix(s, "<+") {
+		return "", false
+	}
+	return s, true
+}
+
+// scopedRef resolves an account., org. or project level reference to the
+// scope of the referenced entity.
+func scopedRef(ref, org, project string) (string, string, string) {
+	switch {
+	case strings.HasPrefix(ref, "account."):
+		return "", "", strings.TrimPrefix(ref, "account.")
+	case strings.HasPrefix(ref, "org."):
+		return org, "", strings.TrimPrefix(ref, "org.")
+	default:
+		return org, project, ref
+	}
+}
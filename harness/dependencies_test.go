@@ -0,0 +1,83 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const dependentPipelineYAML = `pipeline:
+  identifier: deploy
+  stages:
+    - stage:
+        identifier: build
+        template:
+          templateRef: account.build_stage
+          versionLabel: v2
+    - stage:
+        identifier: deploy
+        spec:
+          service:
+            serviceRef: api
+          environment:
+            environmentRef: org.prod
+            infrastructureDefinitions:
+              - identifier: k8s
+          execution:
+            steps:
+              - step:
+                  template:
+                    templateRef: notify
+    - stage:
+        identifier: dynamic
+        spec:
+          service:
+            serviceRef: <+input>
+`
+
+func Test_ExtractReferences_Pipeline(t *testing.T) {
+	refs := ExtractReferences(dependentPipelineYAML, "default", "p1")
+	assert.ElementsMatch(t, []EntityRef{
+		{Type: TemplateEntity, Identifier: "build_stage", VersionLabel: "v2"},
+		{Type: TemplateEntity, Org: "default", Project: "p1", Identifier: "notify"},
+		{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api"},
+		{Type: EnvironmentEntity, Org: "default", Identifier: "prod"},
+		{Type: InfrastructureEntity, Org: "default", Identifier: "k8s", Environment: "prod"},
+	}, refs)
+	assert.Empty(t, ExtractReferences("not: [valid", "default", "p1"))
+}
+
+func Test_DependencyLevels_OrdersDependenciesFirst(t *testing.T) {
+	entries := []PlanEntry{
+		{Type: InputSetEntity, Org: "default", Project: "p1", Pipeline: "deploy", Identifier: "prod-inputs"},
+		{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "deploy", References: ExtractReferences(dependentPipelineYAML, "default", "p1")},
+		{Type: TemplateEntity, Org: "default", Project: "p1", Identifier: "notify", VersionLabel: "v1"},
+		{Type: TemplateEntity, Identifier: "build_stage", VersionLabel: "v1"},
+		{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api"},
+	}
+
+	levels, deps, cyclic := DependencyLevels(entries)
+	assert.False(t, cyclic)
+	assert.Equal(t, [][]int{{2, 3, 4}, {1}, {0}}, levels)
+	// build_stage v1 is not the version the pipeline references
+	assert.ElementsMatch(t, []int{2, 4}, deps[1])
+	assert.Equal(t, []int{1}, deps[0])
+
+	var order []string
+	for _, e := range DependencyOrder(entries) {
+		order = append(order, e.Identifier)
+	}
+	assert.Equal(t, []string{"notify", "build_stage", "api", "deploy", "prod-inputs"}, order)
+}
+
+func Test_DependencyLevels_Cycle(t *testing.T) {
+	entries := []PlanEntry{
+		{Type: ServiceEntity, Org: "o", Project: "p", Identifier: "a", References: []EntityRef{{Type: TemplateEntity, Org: "o", Project: "p", Identifier: "t"}}},
+		{Type: TemplateEntity, Org: "o", Project: "p", Identifier: "t", References: []EntityRef{{Type: ServiceEntity, Org: "o", Project: "p", Identifier: "a"}}},
+		{Type: EnvironmentEntity, Org: "o", Project: "p", Identifier: "e"},
+	}
+
+	levels, _, cyclic := DependencyLevels(entries)
+	assert.True(t, cyclic)
+	assert.Equal(t, [][]int{{2}, {1, 0}}, levels)
+}
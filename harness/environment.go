@@ -1,11 +1,5 @@
 package harness
 
-import (
-	"log"
-
-	"gopkg.in/yaml.v2"
-)
-
 type Environment struct {
 	Status        string          `json:"status"`
 	Data          EnvironmentData `json:"data"`
@@ -34,21 +28,18 @@ type EnvironmentContent struct {
 }
 
 type EnvironmentClass struct {
-	AccountID         string          `json:"accountId"`
-	OrgIdentifier     string          `json:"orgIdentifier"`
-	ProjectIdentifier string          `json:"projectIdentifier"`
-	Identifier        string          `json:"identifier"`
-	Name              string          `json:"name"`
-	Description       string          `json:"description"`
-	Color             string          `json:"color"`
-	Type              string          `json:"type"`
-	Deleted           bool            `json:"deleted"`
-	Tags              EnvironmentTags `json:"tags"`
-	YAML              string          `json:"yaml"`
-	StoreType         string          `json:"storeType"`
-}
-
-type EnvironmentTags struct {
+	AccountID         string `json:"accountId"`
+	OrgIdentifier     string `json:"orgIdentifier"`
+	ProjectIdentifier string `json:"projectIdentifier"`
+	Identifier        string `json:"identifier"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Color             string `json:"color"`
+	Type              string `json:"type"`
+	Deleted           bool   `json:"deleted"`
+	Tags              Tags   `json:"tags"`
+	YAML              string `json:"yaml"`
+	StoreType         string `json:"storeType"`
 }
 
 type EnvironmentYaml struct {
@@ -91,44 +82,6 @@ type ServiceOverrideContent struct {
 	YAML              string `json:"yaml"`
 }
 
-type ServiceOverrideYaml struct {
-	ServiceOverrides struct {
-		EnvironmentRef string `yaml:"environmentRef"`
-		ServiceRef     string `yaml:"serviceRef"`
-		Manifests      []struct {
-			Manifest struct {
-				Identifier string `yaml:"identifier"`
-				Type       string `yaml:"type"`
-				Spec       struct {
-					Store struct {
-						Type string `yaml:"type"`
-						Spec struct {
-							ConnectorRef              string   `yaml:"connectorRef"`
-							GitFetchType              string   `yaml:"gitFetchType"`
-							Paths                     []string `yaml:"paths"`
-							Branch                    string   `yaml:"branch"`
-							Files                     []string `yaml:"files"`
-							SkipResourceVersioning    bool     `yaml:"skipResourceVersioning"`
-							EnableDeclarativeRollback bool     `yaml:"enableDeclarativeRollback"`
-						} `yaml:"spec"`
-					} `yaml:"store"`
-					ValuesPaths []string `yaml:"valuesPaths"`
-				} `yaml:"spec"`
-			} `yaml:"manifest"`
-		} `yaml:"manifests"`
-	} `yaml:"serviceOverrides"`
-}
-
-func (env *ServiceOverrideContent) ParseYAML() (*ServiceOverrideYaml, error) {
-	envYaml := &ServiceOverrideYaml{}
-	err := yaml.Unmarshal([]byte(env.YAML), &envYaml)
-	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
-	}
-
-	return envYaml, nil
-}
-
 func (env *ServiceOverrideContent) UpdateEnvironment(api *APIRequest) error {
 	enviroment := &EnvironmentRequest{
 		OrgIdentifier:         env.OrgIdentifier,
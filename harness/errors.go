@@ -0,0 +1,40 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+)
+
+// APIError is returned when Harness answers a request with a non-200 status.
+type APIError struct {
+	StatusCode       int
+	CorrelationID    string
+	Message          string
+	ResponseMessages []ResponseMessage
+}
+
+func newAPIError(statusCode int, ar ApiResponse) *APIError {
+	return &APIError{
+		StatusCode:       statusCode,
+		CorrelationID:    ar.CorrelationID,
+		Message:          ar.Message,
+		ResponseMessages: ar.ResponseMessages,
+	}
+}
+
+func (e *APIError) Error() string {
+	if len(e.ResponseMessages) == 0 && len(e.Message) > 0 {
+		return fmt.Sprintf("CorrelationId: %s, Message: %s", e.CorrelationID, e.Message)
+	}
+	return fmt.Sprintf("CorrelationId: %s, ResponseMessages: %+v", e.CorrelationID, e.ResponseMessages)
+}
+
+// CorrelationID returns the correlation ID of the Harness request that caused
+// err, or an empty string when err does not come from an API response.
+func CorrelationID(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.CorrelationID
+	}
+	return ""
+}
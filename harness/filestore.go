@@ -2,6 +2,12 @@ package harness
 
 // Generated by https://quicktype.io
 
+// Types of the file store entries.
+const (
+	FileStoreFile   = "FILE"
+	FileStoreFolder = "FOLDER"
+)
+
 type FileStore struct {
 	Status        string        `json:"status"`
 	Data          FileStoreData `json:"data"`
@@ -27,6 +33,7 @@ type FileStoreContent struct {
 	AccountIdentifier string        `json:"accountIdentifier"`
 	Identifier        string        `json:"identifier"`
 	Name              string        `json:"name"`
+	Description       string        `json:"description"`
 	FileUsage         string        `json:"fileUsage"`
 	Type              string        `json:"type"`
 	ParentIdentifier  string        `json:"parentIdentifier"`
@@ -0,0 +1,121 @@
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileMetadataFile is the index written in the folder of every file store
+// scope with the metadata of its files.
+const FileMetadataFile = ".filestore-metadata.yaml"
+
+// FileMetadata holds the fields of a file store file that git does not keep.
+type FileMetadata struct {
+	Identifier     string `yaml:"identifier" json:"identifier"`
+	Name           string `yaml:"name" json:"name"`
+	Path           string `yaml:"path" json:"path"`
+	Type           string `yaml:"type" json:"type"`
+	FileUsage      string `yaml:"fileUsage,omitempty" json:"fileUsage,omitempty"`
+	MIMEType       string `yaml:"mimeType,omitempty" json:"mimeType,omitempty"`
+	Description    string `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags           Tags   `yaml:"tags,omitempty" json:"tags,omitempty"`
+	CreatedBy      EdBy   `yaml:"createdBy" json:"createdBy"`
+	LastModifiedBy EdBy   `yaml:"lastModifiedBy" json:"lastModifiedBy"`
+	LastModifiedAt int64  `yaml:"lastModifiedAt" json:"lastModifiedAt"`
+}
+
+// Metadata returns the metadata of the file kept next to it in the repo.
+func (f FileStoreContent) Metadata() FileMetadata {
+	return FileMetadata{
+		Identifier:     f.Identifier,
+		Name:           f.Name,
+		Path:           f.Path,
+		Type:           f.Type,
+		FileUsage:      f.FileUsage,
+		MIMEType:       f.MIMEType,
+		Description:    f.Description,
+		Tags:           f.TagMap(),
+		CreatedBy:      f.CreatedBy,
+		LastModifiedBy: f.LastModifiedBy,
+		LastModifiedAt: f.LastModifiedAt,
+	}
+}
+
+// TagMap converts the key value list of file store tags to Tags.
+func (f FileStoreContent) TagMap() Tags {
+	tags := Tags{}
+	for _, t := range f.Tags {
+		tag, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, _ := tag["key"].(string)
+		value, _ := tag["value"].(string)
+		if len(key) > 0 {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// FileMetadataIndex collects the metadata of the synced files per scope. It is
+// safe for concurrent use.
+type FileMetadataIndex struct {
+	mu     sync.Mutex
+	scopes map[string][]FileMetadata
+}
+
+func NewFileMetadataIndex() *FileMetadataIndex {
+	return &FileMetadataIndex{scopes: map[string][]FileMetadata{}}
+}
+
+// Listed records that the org and project file store was listed, its index is
+// written even when it has no files.
+func (idx *FileMetadataIndex) Listed(org, project string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	folder := scopeFolderPath(org, project)
+	if _, ok := idx.scopes[folder]; !ok {
+		idx.scopes[folder] = nil
+	}
+}
+
+func (idx *FileMetadataIndex) Add(org, project string, f FileStoreContent) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	folder := scopeFolderPath(org, project)
+	idx.scopes[folder] = append(idx.scopes[folder], f.Metadata())
+}
+
+// Write writes the index of every scope under dir, files sorted by path.
+func (idx *FileMetadataIndex) Write(dir string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for folder, files := range idx.scopes {
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		data, err := yaml.Marshal(struct {
+			Files []FileMetadata `yaml:"files"`
+		}{files})
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, folder, FileMetadataFile)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeFolderPath is the folder of the org and project file store in the
+// pushed repo.
+func scopeFolderPath(org, project string) string {
+	return FileStoreRef{Org: org, Project: project}.RepoPath()
+}
@@ -0,0 +1,73 @@
+package harness
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestFileMetadataIndex_Write(t *testing.T) {
+	idx := NewFileMetadataIndex()
+	idx.Listed("", "")
+	idx.Listed("org", "")
+	idx.Add("", "", FileStoreContent{Identifier: "values", Name: "values.yaml", Path: "/values.yaml", Type: FileStoreFile})
+	idx.Add("", "", FileStoreContent{
+		Identifier:  "script",
+		Name:        "init.sh",
+		Path:        "/scripts/init.sh",
+		Type:        FileStoreFile,
+		FileUsage:   "SCRIPT",
+		MIMEType:    "sh",
+		Description: "Bootstraps the host",
+		Tags:        []interface{}{map[string]interface{}{"key": "team", "value": "payments"}},
+		CreatedBy:   EdBy{Name: "Jane", Email: "jane@example.com"},
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, idx.Write(dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, "filestore/account", FileMetadataFile))
+	require.NoError(t, err)
+	var index struct {
+		Files []FileMetadata `yaml:"files"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &index))
+	require.Len(t, index.Files, 2)
+	assert.Equal(t, "/scripts/init.sh", index.Files[0].Path)
+	assert.Equal(t, "SCRIPT", index.Files[0].FileUsage)
+	assert.Equal(t, "sh", index.Files[0].MIMEType)
+	assert.Equal(t, "Bootstraps the host", index.Files[0].Description)
+	assert.Equal(t, Tags{"team": "payments"}, index.Files[0].Tags)
+	assert.Equal(t, "jane@example.com", index.Files[0].CreatedBy.Email)
+	assert.Equal(t, "/values.yaml", index.Files[1].Path)
+
+	// Listed scopes without files still get an index
+	data, err = os.ReadFile(filepath.Join(dir, "filestore/org", FileMetadataFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "files: []")
+}
+
+func TestNewFileRecord(t *testing.T) {
+	f := FileStoreContent{Identifier: "values", Name: "values.yaml", Path: "/manifests/values.yaml", Type: FileStoreFile, FileUsage: "MANIFEST_FILE"}
+
+	record := NewFileRecord("org", "proj", f, nil)
+	assert.Equal(t, FileEntity, record.Type)
+	assert.Equal(t, "org/proj", record.Scope)
+	assert.Equal(t, "filestore/org/proj/manifests/values.yaml", record.TargetPath)
+	assert.Equal(t, StatusSuccess, record.Status)
+	require.NotNil(t, record.Metadata)
+	assert.Equal(t, "MANIFEST_FILE", record.Metadata.FileUsage)
+
+	record = NewFileRecord("", "", f, errors.New("download failed"))
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, "download failed", record.Error)
+
+	record = NewSkippedFileRecord("", "", f, "excluded by filters")
+	assert.Equal(t, StatusSkipped, record.Status)
+	assert.Equal(t, "excluded by filters", record.Reason)
+}
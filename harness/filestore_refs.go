@@ -0,0 +1,59 @@
+package harness
+
+import (
+	"path"
+	"strings"
+)
+
+// FileStoreRoot is the folder of the pushed file store repo the files are
+// downloaded to.
+const FileStoreRoot = "filestore"
+
+// FileStoreRef is a reference to a file or folder of the Harness file store.
+// Account files have no org, org files have no project.
+type FileStoreRef struct {
+	Org     string
+	Project string
+	Path    string
+}
+
+// ParseFileStoreRef resolves the scope of a file store reference made from an
+// entity in org and project. References prefixed with account: or org: point
+// to the account or org file store, others to the file store of the entity.
+func ParseFileStoreRef(ref, org, project string) FileStoreRef {
+	switch {
+	case strings.HasPrefix(ref, "account:"):
+		return FileStoreRef{Path: strings.TrimPrefix(ref, "account:")}
+	case strings.HasPrefix(ref, "org:"):
+		return FileStoreRef{Org: org, Path: strings.TrimPrefix(ref, "org:")}
+	default:
+		return FileStoreRef{Org: org, Project: project, Path: ref}
+	}
+}
+
+// RepoPath is the path of the file in the pushed file store repo, matching the
+// folders DownloadFile writes the files of every scope to.
+func (r FileStoreRef) RepoPath() string {
+	switch {
+	case len(r.Org) == 0:
+		return path.Join(FileStoreRoot, "account", r.Path)
+	case len(r.Project) == 0:
+		return path.Join(FileStoreRoot, r.Org, r.Path)
+	default:
+		return path.Join(FileStoreRoot, r.Org, r.Project, r.Path)
+	}
+}
+
+// FileIndex holds the repo paths of the files and folders that were
+// downloaded from the file store.
+type FileIndex map[string]bool
+
+// Add records a downloaded file of the org and project file store.
+func (idx FileIndex) Add(org, project string, f FileStoreContent) {
+	idx[FileStoreRef{Org: org, Project: project, Path: f.Path}.RepoPath()] = true
+}
+
+// Contains reports whether the reference points to a downloaded file.
+func (idx FileIndex) Contains(ref FileStoreRef) bool {
+	return idx[ref.RepoPath()]
+}
@@ -0,0 +1,75 @@
+package harness
+
+import "strings"
+
+// FileStoreScope selects the file stores that are migrated. The files of the
+// selected projects are always migrated, org files only for the orgs of the
+// selected projects unless AllOrgs is set and account files only when
+// AccountFiles is set.
+type FileStoreScope struct {
+	AccountFiles bool `yaml:"account_files"`
+	AllOrgs      bool `yaml:"all_orgs"`
+}
+
+// AccountScope is the scope of FileStoreTarget selecting the account files.
+const AccountScope = "account"
+
+// FileStoreTarget is a repo the files of a file store scope are pushed to.
+// Scope is account, an org or org/project. Files of an org include the files
+// of its projects unless a project has a target of its own. ConnectorRef is
+// the connector manifests and file store references read the files of the
+// repo with.
+type FileStoreTarget struct {
+	Scope        string `yaml:"scope"`
+	URL          string `yaml:"url"`
+	Branch       string `yaml:"branch"`
+	Workspace    string `yaml:"workspace"`
+	ConnectorRef string `yaml:"connector_ref"`
+}
+
+// matches reports how specifically the target selects the org and project
+// file store, 0 when it does not.
+func (t FileStoreTarget) matches(org, project string) int {
+	switch {
+	case t.Scope == AccountScope:
+		if len(org) == 0 {
+			return 1
+		}
+	case strings.Contains(t.Scope, "/"):
+		if t.Scope == org+"/"+project {
+			return 2
+		}
+	case len(t.Scope) > 0 && t.Scope == org:
+		return 1
+	}
+	return 0
+}
+
+// Targets returns the repo of fileStoreConfig followed by the repos of every
+// scope, with their branch and workspace set.
+func (c FileStoreConfig) Targets() []FileStoreTarget {
+	targets := []FileStoreTarget{{URL: c.RepositoryURL, Branch: c.Branch, Workspace: c.WorkspaceDir()}}
+	for _, t := range c.Repos {
+		if len(t.Branch) == 0 {
+			t.Branch = c.Branch
+		}
+		if len(t.Workspace) == 0 {
+			t.Workspace = c.WorkspaceDir() + "-" + strings.ReplaceAll(t.Scope, "/", "-")
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// Target returns the repo the files of the org and project file store are
+// pushed to, the most specific of Repos or the repo of fileStoreConfig.
+func (c FileStoreConfig) Target(org, project string) FileStoreTarget {
+	targets := c.Targets()
+	target, best := targets[0], 0
+	for _, t := range targets[1:] {
+		if m := t.matches(org, project); m > best {
+			target, best = t, m
+		}
+	}
+	return target
+}
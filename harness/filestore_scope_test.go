@@ -0,0 +1,28 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStoreConfig_Target(t *testing.T) {
+	c := FileStoreConfig{
+		RepositoryURL: "https://git.example.com/filestore.git",
+		Branch:        "migration",
+		Repos: []FileStoreTarget{
+			{Scope: "account", URL: "https://git.example.com/shared.git"},
+			{Scope: "retail", URL: "https://git.example.com/retail.git", Branch: "main"},
+			{Scope: "retail/payments", URL: "https://git.example.com/payments.git", Workspace: "payments"},
+		},
+	}
+
+	assert.Equal(t, FileStoreTarget{Scope: "account", URL: "https://git.example.com/shared.git", Branch: "migration", Workspace: "filestore-account"}, c.Target("", ""))
+	assert.Equal(t, "https://git.example.com/retail.git", c.Target("retail", "").URL)
+	assert.Equal(t, "main", c.Target("retail", "").Branch)
+	// Projects use the repo of their org unless they have their own
+	assert.Equal(t, "filestore-retail", c.Target("retail", "web").Workspace)
+	assert.Equal(t, "payments", c.Target("retail", "payments").Workspace)
+	// Other scopes go to the repo of fileStoreConfig
+	assert.Equal(t, FileStoreTarget{URL: "https://git.example.com/filestore.git", Branch: "migration", Workspace: "filestore"}, c.Target("wholesale", "orders"))
+}
@@ -0,0 +1,222 @@
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SyncManifestFile is the file in the workspace, and in the pushed repo, that
+// records the files of the last file store sync.
+const SyncManifestFile = ".filestore-sync.json"
+
+// SyncManifest lists the synced files by their path in the repo.
+type SyncManifest struct {
+	Files map[string]SyncedFile `json:"files"`
+}
+
+// SyncedFile is a file store file as it was when it was synced.
+type SyncedFile struct {
+	Identifier     string `json:"identifier"`
+	Org            string `json:"org,omitempty"`
+	Project        string `json:"project,omitempty"`
+	Path           string `json:"path"`
+	LastModifiedAt int64  `json:"lastModifiedAt"`
+	SHA256         string `json:"sha256"`
+}
+
+// FileStoreSync compares the files of a file store sync with the previous
+// sync of the workspace. Unchanged files are not downloaded again and files
+// deleted from Harness are removed. Only the files of the scopes that were
+// listed are compared, the others are kept as they are. Files can be added
+// concurrently.
+type FileStoreSync struct {
+	Dir      string
+	Previous SyncManifest
+	Current  SyncManifest
+
+	mu     sync.Mutex
+	listed map[string]bool
+	// saved is the manifest Save replaced, nil when there was none
+	saved []byte
+}
+
+// NewFileStoreSync starts a sync of the workspace dir. The previous sync is
+// only read when incremental, otherwise every file is downloaded and nothing
+// is removed.
+func NewFileStoreSync(dir string, incremental bool) (*FileStoreSync, error) {
+	s := &FileStoreSync{
+		Dir:      dir,
+		Previous: SyncManifest{Files: map[string]SyncedFile{}},
+		Current:  SyncManifest{Files: map[string]SyncedFile{}},
+		listed:   map[string]bool{},
+	}
+	if !incremental {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, SyncManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.Previous); err != nil {
+		return nil, err
+	}
+	if s.Previous.Files == nil {
+		s.Previous.Files = map[string]SyncedFile{}
+	}
+	return s, nil
+}
+
+// Listed records that the files of the org and project file store were
+// listed, so the ones missing from the listing are removed.
+func (s *FileStoreSync) Listed(org, project string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listed[org+"/"+project] = true
+}
+
+// Unchanged reports whether the file was synced with the same modification
+// time and its copy in the workspace is intact, or is a folder. Unchanged
+// files are recorded.
+func (s *FileStoreSync) Unchanged(org, project string, f FileStoreContent) bool {
+	// Folders have nothing to download
+	if f.Type == FileStoreFolder {
+		return true
+	}
+	repoPath := FileStoreRef{Org: org, Project: project, Path: f.Path}.RepoPath()
+	previous, ok := s.Previous.Files[repoPath]
+	if !ok || previous.Identifier != f.Identifier || previous.LastModifiedAt != f.LastModifiedAt {
+		return false
+	}
+	if sum, err := hashFile(filepath.Join(s.Dir, repoPath)); err != nil || sum != previous.SHA256 {
+		return false
+	}
+	s.add(previous)
+	return true
+}
+
+// Record adds a downloaded file to the sync. Folders have no content and are
+// not recorded.
+func (s *FileStoreSync) Record(org, project string, f FileStoreContent) error {
+	repoPath := FileStoreRef{Org: org, Project: project, Path: f.Path}.RepoPath()
+	local := filepath.Join(s.Dir, repoPath)
+	if info, err := os.Stat(local); errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil
+	}
+	sum, err := hashFile(local)
+	if err != nil {
+		return err
+	}
+	s.add(SyncedFile{
+		Identifier:     f.Identifier,
+		Org:            org,
+		Project:        project,
+		Path:           repoPath,
+		LastModifiedAt: f.LastModifiedAt,
+		SHA256:         sum,
+	})
+	return nil
+}
+
+// Keep carries the previous sync of a file that was filtered out or failed to
+// download, so it is not removed. It reports whether the file was synced
+// before and stays in the repo.
+func (s *FileStoreSync) Keep(org, project string, f FileStoreContent) bool {
+	repoPath := FileStoreRef{Org: org, Project: project, Path: f.Path}.RepoPath()
+	previous, ok := s.Previous.Files[repoPath]
+	if ok {
+		s.add(previous)
+	}
+	return ok
+}
+
+func (s *FileStoreSync) add(f SyncedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Current.Files[f.Path] = f
+}
+
+// Removed returns the repo paths of the previously synced files that are no
+// longer in the file store, in path order. Files of scopes that were not
+// listed are kept.
+func (s *FileStoreSync) Removed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []string
+	for repoPath, f := range s.Previous.Files {
+		if _, ok := s.Current.Files[repoPath]; ok {
+			continue
+		}
+		if !s.listed[f.Org+"/"+f.Project] {
+			s.Current.Files[repoPath] = f
+			continue
+		}
+		removed = append(removed, repoPath)
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Save deletes the removed files from the workspace and writes the manifest
+// of the sync next to the files.
+func (s *FileStoreSync) Save(removed []string) error {
+	for _, repoPath := range removed {
+		err := os.Remove(filepath.Join(s.Dir, repoPath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(s.Current, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, SyncManifestFile)
+	saved, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.saved = saved
+	return os.WriteFile(path, data, 0644)
+}
+
+// Restore puts back the manifest Save replaced when the sync was not pushed,
+// so the next sync removes the deleted files again.
+func (s *FileStoreSync) Restore() error {
+	path := filepath.Join(s.Dir, SyncManifestFile)
+	if s.saved == nil {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(path, s.saved, 0644)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,124 @@
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreSync_Incremental(t *testing.T) {
+	dir := t.TempDir()
+	values := FileStoreContent{Identifier: "values", Path: "/values.yaml", LastModifiedAt: 1}
+	script := FileStoreContent{Identifier: "script", Path: "/deploy.sh", LastModifiedAt: 1}
+	shared := FileStoreContent{Identifier: "shared", Path: "/shared.yaml", LastModifiedAt: 1}
+
+	// First sync downloads everything
+	first, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	writeFiles(t, dir, map[string]string{
+		"filestore/org/project/values.yaml": "replicas: 1",
+		"filestore/org/project/deploy.sh":   "echo deploy",
+		"filestore/account/shared.yaml":     "shared",
+	})
+	for _, f := range []FileStoreContent{values, script} {
+		assert.False(t, first.Unchanged("org", "project", f))
+		require.NoError(t, first.Record("org", "project", f))
+	}
+	require.NoError(t, first.Record("", "", shared))
+	first.Listed("", "")
+	first.Listed("org", "project")
+	assert.Empty(t, first.Removed())
+	require.NoError(t, first.Save(nil))
+
+	// The script is deleted from Harness, the values are modified and the
+	// account was not listed
+	second, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	second.Listed("org", "project")
+	values.LastModifiedAt = 2
+	assert.False(t, second.Unchanged("org", "project", values))
+	require.NoError(t, second.Record("org", "project", values))
+	assert.True(t, second.Unchanged("org", "", FileStoreContent{Type: FileStoreFolder, Path: "/charts"}))
+
+	removed := second.Removed()
+	assert.Equal(t, []string{"filestore/org/project/deploy.sh"}, removed)
+	assert.Contains(t, second.Current.Files, "filestore/account/shared.yaml")
+	require.NoError(t, second.Save(removed))
+	assert.NoFileExists(t, filepath.Join(dir, "filestore/org/project/deploy.sh"))
+}
+
+func TestFileStoreSync_ModifiedWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	values := FileStoreContent{Identifier: "values", Path: "/values.yaml", LastModifiedAt: 1}
+	writeFiles(t, dir, map[string]string{"filestore/account/values.yaml": "replicas: 1"})
+
+	first, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	require.NoError(t, first.Record("", "", values))
+	require.NoError(t, first.Save(nil))
+
+	// A local copy that no longer matches is downloaded again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "filestore/account/values.yaml"), []byte("replicas: 2"), 0644))
+	second, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	assert.False(t, second.Unchanged("", "", values))
+
+	// Without incremental sync every file is downloaded
+	full, err := NewFileStoreSync(dir, false)
+	require.NoError(t, err)
+	assert.Empty(t, full.Previous.Files)
+}
+
+func TestFileStoreSync_RestoreAfterFailedPush(t *testing.T) {
+	dir := t.TempDir()
+	script := FileStoreContent{Identifier: "script", Path: "/deploy.sh", LastModifiedAt: 1}
+	writeFiles(t, dir, map[string]string{"filestore/org/project/deploy.sh": "echo deploy"})
+
+	first, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	require.NoError(t, first.Record("org", "project", script))
+	require.NoError(t, first.Save(nil))
+
+	// The script is deleted from Harness but the push fails
+	second, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	second.Listed("org", "project")
+	removed := second.Removed()
+	require.NoError(t, second.Save(removed))
+	require.NoError(t, second.Restore())
+
+	third, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	third.Listed("org", "project")
+	assert.Equal(t, removed, third.Removed())
+
+	// Without a previous manifest the new one is removed
+	fresh, err := NewFileStoreSync(t.TempDir(), false)
+	require.NoError(t, err)
+	require.NoError(t, fresh.Save(nil))
+	require.NoError(t, fresh.Restore())
+	assert.NoFileExists(t, filepath.Join(fresh.Dir, SyncManifestFile))
+}
+
+func TestFileStoreSync_Keep(t *testing.T) {
+	dir := t.TempDir()
+	values := FileStoreContent{Identifier: "values", Path: "/values.yaml", LastModifiedAt: 1}
+	writeFiles(t, dir, map[string]string{"filestore/org/values.yaml": "replicas: 1"})
+
+	first, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	require.NoError(t, first.Record("org", "", values))
+	require.NoError(t, first.Save(nil))
+
+	// A file that failed to download stays in the repo, a new one does not
+	second, err := NewFileStoreSync(dir, true)
+	require.NoError(t, err)
+	second.Listed("org", "")
+	values.LastModifiedAt = 2
+	assert.True(t, second.Keep("org", "", values))
+	assert.False(t, second.Keep("org", "", FileStoreContent{Identifier: "new", Path: "/new.yaml"}))
+	assert.Empty(t, second.Removed())
+}
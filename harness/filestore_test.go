@@ -0,0 +1,64 @@
+package harness
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStoreContent_DownloadFile(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		assert.Equal(t, "/ng/api/file-store/files/dockerfile/download", r.URL.Path)
+		assert.Equal(t, "org", r.URL.Query().Get("orgIdentifier"))
+		assert.False(t, r.URL.Query().Has("projectIdentifier"))
+		if hits == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("partial"))
+			return
+		}
+		w.Write([]byte("FROM alpine"))
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	api.SetRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, testLogger{t})
+	dir := t.TempDir()
+
+	// Files without an extension are files too
+	file := FileStoreContent{Identifier: "dockerfile", Type: FileStoreFile, Path: "/images/Dockerfile"}
+	assert.NoError(t, file.DownloadFile(&api, "acc", "org", "", dir))
+	content, err := os.ReadFile(filepath.Join(dir, "images", "Dockerfile"))
+	assert.NoError(t, err)
+	assert.Equal(t, "FROM alpine", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "images", "Dockerfile.download"))
+
+	// Folders are created without calling the API
+	folder := FileStoreContent{Identifier: "charts", Type: FileStoreFolder, Path: "/charts.v2"}
+	assert.NoError(t, folder.DownloadFile(&api, "acc", "org", "", dir))
+	assert.DirExists(t, filepath.Join(dir, "charts.v2"))
+	assert.Equal(t, 2, hits)
+}
+
+func TestFileStoreContent_DownloadFileError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":"ERROR","code":"RESOURCE_NOT_FOUND","message":"File not found"}`))
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	dir := t.TempDir()
+	file := FileStoreContent{Identifier: "missing", Type: FileStoreFile, Path: "/missing.yaml"}
+	err := file.DownloadFile(&api, "acc", "", "", dir)
+	assert.ErrorContains(t, err, "File not found")
+	assert.NoFileExists(t, filepath.Join(dir, "missing.yaml"))
+	assert.NoFileExists(t, filepath.Join(dir, "missing.yaml.download"))
+}
@@ -0,0 +1,188 @@
+package harness
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Entity types that are only used to filter entities, files and service
+// overrides are not moved with move-config.
+const (
+	FileEntity      EntityType = "file"
+	OverridesEntity EntityType = "overrides"
+)
+
+// Tags are the key value tags of an entity, a tag without a value has an
+// empty value.
+type Tags map[string]string
+
+// EntityFilters selects the entities of every type that are migrated. An
+// entity is migrated when it matches one of the include rules of its type, or
+// when no include rule applies to its type, and matches none of the exclude
+// rules.
+type EntityFilters struct {
+	Include []FilterRule `yaml:"include"`
+	Exclude []FilterRule `yaml:"exclude"`
+}
+
+// FilterRule matches entities on every field that is set. Identifier and Name
+// are globs, Regex is matched against the identifier. A tag with an empty
+// value matches every value of the tag. ChildType only matches templates and
+// Module only matches pipelines. A rule without Types applies to every type.
+type FilterRule struct {
+	Types      []EntityType `yaml:"types"`
+	Identifier string       `yaml:"identifier"`
+	Regex      string       `yaml:"regex"`
+	Name       string       `yaml:"name"`
+	Tags       Tags         `yaml:"tags"`
+	ChildType  string       `yaml:"childType"`
+	Module     string       `yaml:"module"`
+
+	regex *regexp.Regexp
+}
+
+// FilterSubject holds the attributes of an entity the filters match against.
+type FilterSubject struct {
+	Type       EntityType
+	Identifier string
+	Name       string
+	Tags       Tags
+	ChildType  string
+	Modules    []string
+	// EnvironmentType is only used to route environments and their
+	// infrastructures
+	EnvironmentType string
+}
+
+// Compile validates the globs and compiles the regular expressions of every
+// rule. It has to be called before the filters are used.
+func (f *EntityFilters) Compile() error {
+	for _, rules := range [][]FilterRule{f.Include, f.Exclude} {
+		for i := range rules {
+			r := &rules[i]
+			for _, glob := range []string{r.Identifier, r.Name} {
+				if _, err := path.Match(glob, ""); err != nil {
+					return fmt.Errorf("invalid filter glob %q - %w", glob, err)
+				}
+			}
+			if len(r.Regex) > 0 {
+				regex, err := regexp.Compile(r.Regex)
+				if err != nil {
+					return fmt.Errorf("invalid filter regex %q - %w", r.Regex, err)
+				}
+				r.regex = regex
+			}
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the entity is migrated.
+func (f *EntityFilters) Allows(s FilterSubject) bool {
+	included := true
+	for _, r := range f.Include {
+		if !r.appliesTo(s.Type) {
+			continue
+		}
+		if r.matches(s) {
+			included = true
+			break
+		}
+		included = false
+	}
+	if !included {
+		return false
+	}
+
+	for _, r := range f.Exclude {
+		if r.appliesTo(s.Type) && r.matches(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r FilterRule) appliesTo(t EntityType) bool {
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, ruleType := range r.Types {
+		if ruleType == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (r FilterRule) matches(s FilterSubject) bool {
+	if len(r.Identifier) > 0 && !globMatch(r.Identifier, s.Identifier) {
+		return false
+	}
+	if len(r.Name) > 0 && !globMatch(r.Name, s.Name) {
+		return false
+	}
+	if r.regex != nil && !r.regex.MatchString(s.Identifier) {
+		return false
+	}
+	for key, value := range r.Tags {
+		tag, ok := s.Tags[key]
+		if !ok || (len(value) > 0 && tag != value) {
+			return false
+		}
+	}
+	if len(r.ChildType) > 0 && r.ChildType != s.ChildType {
+		return false
+	}
+	if len(r.Module) > 0 && !contains(s.Modules, r.Module) {
+		return false
+	}
+	return true
+}
+
+func globMatch(glob, s string) bool {
+	ok, _ := path.Match(glob, s)
+	return ok
+}
+
+func (p PipelineContent) FilterSubject() FilterSubject {
+	modules := make([]string, 0, len(p.Modules))
+	for _, m := range p.Modules {
+		modules = append(modules, string(m))
+	}
+	return FilterSubject{Type: PipelineEntity, Identifier: p.Identifier, Name: p.Name, Tags: p.Tags, Modules: modules}
+}
+
+func (t Template) FilterSubject() FilterSubject {
+	return FilterSubject{Type: TemplateEntity, Identifier: t.Identifier, Name: t.Name, Tags: t.Tags, ChildType: t.ChildType}
+}
+
+func (s ServiceClass) FilterSubject() FilterSubject {
+	return FilterSubject{Type: ServiceEntity, Identifier: s.Identifier, Name: s.Name, Tags: s.Tags}
+}
+
+func (e EnvironmentClass) FilterSubject() FilterSubject {
+	return FilterSubject{Type: EnvironmentEntity, Identifier: e.Identifier, Name: e.Name, Tags: e.Tags, EnvironmentType: e.Type}
+}
+
+func (i Infrastructure) FilterSubject() FilterSubject {
+	return FilterSubject{Type: InfrastructureEntity, Identifier: i.Identifier, Name: i.Name, Tags: i.Tags}
+}
+
+func (is InputsetContent) FilterSubject() FilterSubject {
+	return FilterSubject{Type: InputSetEntity, Identifier: is.Identifier, Name: is.Name, Tags: is.Tags}
+}
+
+func (ov OverridesV2Content) FilterSubject() FilterSubject {
+	return FilterSubject{Type: OverridesV2Entity, Identifier: ov.Identifier}
+}
+
+// FilterSubject matches legacy service overrides by their environment, they
+// have no identifier of their own.
+func (o ServiceOverrideContent) FilterSubject() FilterSubject {
+	return FilterSubject{Type: OverridesEntity, Identifier: o.EnvironmentRef}
+}
+
+func (f FileStoreContent) FilterSubject() FilterSubject {
+	return FilterSubject{Type: FileEntity, Identifier: f.Identifier, Name: f.Name, Tags: f.TagMap()}
+}
@@ -0,0 +1,61 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EntityFilters_Allows(t *testing.T) {
+	f := EntityFilters{
+		Include: []FilterRule{
+			{Types: []EntityType{PipelineEntity}, Identifier: "deploy_*"},
+			{Types: []EntityType{PipelineEntity}, Module: "ci"},
+			{Types: []EntityType{TemplateEntity}, ChildType: "Stage"},
+		},
+		Exclude: []FilterRule{
+			{Regex: "_tmp$"},
+			{Tags: Tags{"migrate": "false"}},
+			{Types: []EntityType{ServiceEntity}, Name: "Legacy *"},
+		},
+	}
+	assert.NoError(t, f.Compile())
+
+	assert.True(t, f.Allows(FilterSubject{Type: PipelineEntity, Identifier: "deploy_api"}))
+	assert.True(t, f.Allows(FilterSubject{Type: PipelineEntity, Identifier: "build", Modules: []string{"ci"}}))
+	assert.False(t, f.Allows(FilterSubject{Type: PipelineEntity, Identifier: "build", Modules: []string{"cd"}}))
+	assert.False(t, f.Allows(FilterSubject{Type: PipelineEntity, Identifier: "deploy_tmp"}))
+
+	assert.True(t, f.Allows(FilterSubject{Type: TemplateEntity, Identifier: "t", ChildType: "Stage"}))
+	assert.False(t, f.Allows(FilterSubject{Type: TemplateEntity, Identifier: "t", ChildType: "Step"}))
+
+	// No include rule applies to services
+	assert.True(t, f.Allows(FilterSubject{Type: ServiceEntity, Identifier: "api", Name: "API"}))
+	assert.False(t, f.Allows(FilterSubject{Type: ServiceEntity, Identifier: "old", Name: "Legacy API"}))
+	assert.False(t, f.Allows(FilterSubject{Type: ServiceEntity, Identifier: "api", Tags: Tags{"migrate": "false"}}))
+	assert.True(t, f.Allows(FilterSubject{Type: ServiceEntity, Identifier: "api", Tags: Tags{"migrate": "true"}}))
+}
+
+func Test_EntityFilters_TagKey(t *testing.T) {
+	f := EntityFilters{Include: []FilterRule{{Tags: Tags{"team": ""}}}}
+	assert.NoError(t, f.Compile())
+
+	assert.True(t, f.Allows(FilterSubject{Type: FileEntity, Identifier: "f", Tags: Tags{"team": "payments"}}))
+	assert.False(t, f.Allows(FilterSubject{Type: FileEntity, Identifier: "f"}))
+}
+
+func Test_EntityFilters_Compile(t *testing.T) {
+	f := EntityFilters{Exclude: []FilterRule{{Regex: "("}}}
+	assert.Error(t, f.Compile())
+
+	f = EntityFilters{Include: []FilterRule{{Identifier: "["}}}
+	assert.Error(t, f.Compile())
+}
+
+func Test_FileStoreContent_FilterSubject(t *testing.T) {
+	file := FileStoreContent{Identifier: "values", Tags: []interface{}{
+		map[string]interface{}{"key": "env", "value": "prod"},
+		map[string]interface{}{"key": "shared"},
+	}}
+	assert.Equal(t, Tags{"env": "prod", "shared": ""}, file.FilterSubject().Tags)
+}
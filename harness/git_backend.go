@@ -0,0 +1,203 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// GitBackend pushes the file store workspace to a git repo.
+type GitBackend interface {
+	// Push commits the files of dir on top of the branch of the repo at url
+	// and pushes the branch. The repo is created in dir when it does not
+	// exist. Files of the remote branch that are not in dir are kept, unless
+	// their path relative to dir is in removed.
+	Push(dir, url, branch, message string, removed []string) error
+}
+
+// Author of the file store commits when none is configured.
+const (
+	DefaultGitAuthorName  = "Harness Migration"
+	DefaultGitAuthorEmail = "migration@harness.io"
+)
+
+// GoGitBackend is a GitBackend that does not depend on a git installation,
+// the git config or the credentials of the host.
+type GoGitBackend struct {
+	Auth   transport.AuthMethod
+	Author GitAuthor
+}
+
+// NewGoGitBackend returns a backend pushing with the credentials and author
+// of the file store config. Without credentials the repo is pushed
+// anonymously, which is enough for local repos.
+func NewGoGitBackend(c FileStoreConfig) (*GoGitBackend, error) {
+	b := &GoGitBackend{Author: c.Author}
+	if len(b.Author.Name) == 0 {
+		b.Author.Name = DefaultGitAuthorName
+	}
+	if len(b.Author.Email) == 0 {
+		b.Author.Email = DefaultGitAuthorEmail
+	}
+
+	username := c.Auth.Username
+	if len(username) == 0 {
+		username = "git"
+	}
+	switch {
+	case len(c.Auth.SSHKeyPath) > 0:
+		keys, err := ssh.NewPublicKeysFromFile(username, c.Auth.SSHKeyPath, c.Auth.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read SSH key %s - %w", c.Auth.SSHKeyPath, err)
+		}
+		b.Auth = keys
+	case len(c.Auth.Token) > 0:
+		b.Auth = &http.BasicAuth{Username: username, Password: c.Auth.Token}
+	}
+	return b, nil
+}
+
+func (b *GoGitBackend) Push(dir, url, branch, message string, removed []string) error {
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(dir, false)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open git repo in %s - %w", dir, err)
+	}
+	if err := setOrigin(repo, url); err != nil {
+		return fmt.Errorf("unable to set remote origin - %w", err)
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef)); err != nil {
+		return fmt.Errorf("unable to check out branch %s - %w", branch, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	remoteHead, err := b.fetch(repo, branch)
+	if err != nil {
+		return fmt.Errorf("unable to fetch branch %s - %w", branch, err)
+	}
+	if remoteHead != nil {
+		// The commit goes on top of the remote branch and the index starts
+		// from its files, so files missing from dir are not deleted
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, *remoteHead)); err != nil {
+			return fmt.Errorf("unable to reset to branch %s - %w", branch, err)
+		}
+		if err := worktree.Reset(&git.ResetOptions{Commit: *remoteHead, Mode: git.MixedReset}); err != nil {
+			return fmt.Errorf("unable to reset to branch %s - %w", branch, err)
+		}
+	}
+
+	changed, err := stageChanges(worktree, removed)
+	if err != nil {
+		return fmt.Errorf("unable to add files - %w", err)
+	}
+	if changed {
+		signature := &object.Signature{Name: b.Author.Name, Email: b.Author.Email, When: time.Now()}
+		if _, err := worktree.Commit(message, &git.CommitOptions{Author: signature}); err != nil {
+			return fmt.Errorf("unable to commit files - %w", err)
+		}
+	}
+	if _, err := repo.Head(); err != nil {
+		return fmt.Errorf("nothing to push, %s has no files", dir)
+	}
+
+	err = repo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(branchRef + ":" + branchRef)},
+		Auth:       b.Auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("unable to push branch %s - %w", branch, err)
+	}
+	return nil
+}
+
+// fetch returns the head of the remote branch, or nil when the branch or the
+// repo is empty.
+func (b *GoGitBackend) fetch(repo *git.Repository, branch string) (*plumbing.Hash, error) {
+	remoteRef := plumbing.NewRemoteReferenceName("origin", branch)
+	err := repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + plumbing.NewBranchReferenceName(branch) + ":" + remoteRef)},
+		Auth:       b.Auth,
+	})
+	switch {
+	case errors.Is(err, transport.ErrEmptyRemoteRepository), errors.Is(err, git.NoMatchingRefSpecError{}):
+		return nil, nil
+	case err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate):
+		return nil, err
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, err
+	}
+	head := ref.Hash()
+	return &head, nil
+}
+
+// setOrigin points the origin remote of the repo to url.
+func setOrigin(repo *git.Repository, url string) error {
+	remote, err := repo.Remote("origin")
+	switch {
+	case errors.Is(err, git.ErrRemoteNotFound):
+	case err != nil:
+		return err
+	case len(remote.Config().URLs) == 1 && remote.Config().URLs[0] == url:
+		return nil
+	default:
+		if err := repo.DeleteRemote("origin"); err != nil {
+			return err
+		}
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	return err
+}
+
+// stageChanges adds the new and modified files of the worktree and reports
+// whether anything was staged. Deleted files are left in the index unless
+// they are in removed.
+func stageChanges(worktree *git.Worktree, removed []string) (bool, error) {
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+	remove := map[string]bool{}
+	for _, path := range removed {
+		remove[path] = true
+	}
+	changed := false
+	for path, s := range status {
+		if s.Worktree == git.Deleted {
+			if remove[path] {
+				if _, err := worktree.Remove(path); err != nil {
+					return false, err
+				}
+				changed = true
+			}
+			continue
+		}
+		if s.Worktree == git.Unmodified && s.Staging == git.Unmodified {
+			continue
+		}
+		if _, err := worktree.Add(path); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
@@ -0,0 +1,105 @@
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func branchFiles(t *testing.T, remote, branch string) map[string]string {
+	repo, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	files, err := commit.Files()
+	require.NoError(t, err)
+
+	contents := map[string]string{}
+	for {
+		f, err := files.Next()
+		if err != nil {
+			break
+		}
+		contents[f.Name], err = f.Contents()
+		require.NoError(t, err)
+	}
+	return contents
+}
+
+func TestGoGitBackend_Push(t *testing.T) {
+	remote := t.TempDir()
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+
+	backend, err := NewGoGitBackend(FileStoreConfig{Author: GitAuthor{Name: "Migrator"}})
+	require.NoError(t, err)
+
+	// A repo that already holds files the workspace does not have
+	seed := t.TempDir()
+	writeFiles(t, seed, map[string]string{"README.md": "file store", "filestore/account/old.yaml": "old"})
+	require.NoError(t, backend.Push(seed, remote, "migration", "Seed", nil))
+
+	workspace := t.TempDir()
+	writeFiles(t, workspace, map[string]string{"filestore/account/old.yaml": "new", "filestore/org/values.yaml": "values"})
+	require.NoError(t, backend.Push(workspace, remote, "migration", "Initial Filestore commit", nil))
+	assert.Equal(t, map[string]string{
+		"README.md":                  "file store",
+		"filestore/account/old.yaml": "new",
+		"filestore/org/values.yaml":  "values",
+	}, branchFiles(t, remote, "migration"))
+
+	// Pushing again without changes is a no-op
+	require.NoError(t, backend.Push(workspace, remote, "migration", "Initial Filestore commit", nil))
+
+	repo, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	head, err := repo.Reference(plumbing.NewBranchReferenceName("migration"), true)
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "Initial Filestore commit", commit.Message)
+	assert.Equal(t, "Migrator", commit.Author.Name)
+	assert.Equal(t, DefaultGitAuthorEmail, commit.Author.Email)
+	assert.Len(t, commit.ParentHashes, 1)
+}
+
+func TestGoGitBackend_PushEmptyWorkspace(t *testing.T) {
+	remote := t.TempDir()
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+
+	backend, err := NewGoGitBackend(FileStoreConfig{})
+	require.NoError(t, err)
+	assert.Error(t, backend.Push(t.TempDir(), remote, "migration", "Initial Filestore commit", nil))
+}
+
+func TestGoGitBackend_PushRemoved(t *testing.T) {
+	remote := t.TempDir()
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+	backend, err := NewGoGitBackend(FileStoreConfig{})
+	require.NoError(t, err)
+
+	workspace := t.TempDir()
+	writeFiles(t, workspace, map[string]string{"filestore/account/a.yaml": "a", "filestore/account/b.yaml": "b"})
+	require.NoError(t, backend.Push(workspace, remote, "migration", "Initial Filestore commit", nil))
+
+	require.NoError(t, os.Remove(filepath.Join(workspace, "filestore/account/b.yaml")))
+	require.NoError(t, backend.Push(workspace, remote, "migration", "Sync Filestore", []string{"filestore/account/b.yaml"}))
+	assert.Equal(t, map[string]string{"filestore/account/a.yaml": "a"}, branchFiles(t, remote, "migration"))
+}
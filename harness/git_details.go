@@ -2,6 +2,7 @@ package harness
 
 import (
 	"fmt"
+	"strings"
 )
 
 func GetPipelineFilePath(gitX bool, customGitDetailsFilePath string, p Project, pipeline PipelineContent) string {
@@ -18,9 +19,9 @@ func GetPipelineFilePath(gitX bool, customGitDetailsFilePath string, p Project,
 func GetTemplateFilePath(gitX bool, customGitDetailsFilePath string, p Project, template Template) string {
 	if len(customGitDetailsFilePath) == 0 {
 		if gitX {
-			return fmt.Sprintf(".harness/orgs/%s/projects/%s/templates/%s/%s.yaml", string(p.OrgIdentifier), p.Identifier, template.Identifier, template.VersionLabel)
+			return fmt.Sprintf("%s/templates/%s/%s.yaml", gitXScope(p), template.Identifier, template.VersionLabel)
 		}
-		return "templates/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/" + template.Identifier + "-" + template.VersionLabel + ".yaml"
+		return scopeFolder("templates", p) + template.Identifier + "-" + template.VersionLabel + ".yaml"
 	} else {
 		return customGitDetailsFilePath + "/" + template.Identifier + "-" + template.VersionLabel + ".yaml"
 	}
@@ -29,9 +30,9 @@ func GetTemplateFilePath(gitX bool, customGitDetailsFilePath string, p Project,
 func GetServiceFilePath(gitX bool, customGitDetailsFilePath string, p Project, service ServiceClass) string {
 	if len(customGitDetailsFilePath) == 0 {
 		if gitX {
-			return fmt.Sprintf(".harness/orgs/%s/projects/%s/services/%s.yaml", string(p.OrgIdentifier), p.Identifier, service.Identifier)
+			return fmt.Sprintf("%s/services/%s.yaml", gitXScope(p), service.Identifier)
 		}
-		return "services/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/" + service.Identifier + ".yaml"
+		return scopeFolder("services", p) + service.Identifier + ".yaml"
 	} else {
 		return customGitDetailsFilePath + "/" + service.Identifier + ".yaml"
 	}
@@ -40,9 +41,9 @@ func GetServiceFilePath(gitX bool, customGitDetailsFilePath string, p Project, s
 func GetEnvironmentFilePath(gitX bool, customGitDetailsFilePath string, p Project, env EnvironmentClass) string {
 	if len(customGitDetailsFilePath) == 0 {
 		if gitX {
-			return fmt.Sprintf(".harness/orgs/%s/projects/%s/envs/%s/%s.yaml", string(p.OrgIdentifier), p.Identifier, getEnvType(env), env.Identifier)
+			return fmt.Sprintf("%s/envs/%s/%s.yaml", gitXScope(p), getEnvType(env), env.Identifier)
 		}
-		return "environments/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/" + env.Identifier + ".yaml"
+		return scopeFolder("environments", p) + env.Identifier + ".yaml"
 	} else {
 		return customGitDetailsFilePath + "/" + env.Identifier + ".yaml"
 	}
@@ -51,9 +52,9 @@ func GetEnvironmentFilePath(gitX bool, customGitDetailsFilePath string, p Projec
 func GetInfrastructureFilePath(gitX bool, customGitDetailsFilePath string, p Project, env EnvironmentClass, infraDef Infrastructure) string {
 	if len(customGitDetailsFilePath) == 0 {
 		if gitX {
-			return fmt.Sprintf(".harness/orgs/%s/projects/%s/envs/%s/%s/infras/%s.yaml", string(p.OrgIdentifier), p.Identifier, getEnvType(env), env.Identifier, infraDef.Identifier)
+			return fmt.Sprintf("%s/envs/%s/%s/infras/%s.yaml", gitXScope(p), getEnvType(env), env.Identifier, infraDef.Identifier)
 		}
-		return "environments/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/" + env.Identifier + "-" + infraDef.Identifier + ".yaml"
+		return scopeFolder("environments", p) + env.Identifier + "-" + infraDef.Identifier + ".yaml"
 	} else {
 		return customGitDetailsFilePath + "/" + env.Identifier + "-" + infraDef.Identifier + ".yaml"
 	}
@@ -64,21 +65,18 @@ func GetOverridesV2FilePath(gitX bool, customGitDetailsFilePath string, p Projec
 		if gitX {
 			switch ov.Type {
 			case OV2_Global:
-				return fmt.Sprintf(".harness/orgs/%s/projects/%s/overrides/%s/overrides.yaml", string(p.OrgIdentifier), p.Identifier, ov.EnvironmentRef)
+				return fmt.Sprintf("%s/overrides/%s/overrides.yaml", gitXScope(p), ov.EnvironmentRef)
 			case OV2_Service:
-				return fmt.Sprintf(".harness/orgs/%s/projects/%s/overrides/%s/services/%s/overrides.yaml",
-					string(p.OrgIdentifier), p.Identifier, ov.EnvironmentRef, ov.ServiceRef)
+				return fmt.Sprintf("%s/overrides/%s/services/%s/overrides.yaml", gitXScope(p), ov.EnvironmentRef, ov.ServiceRef)
 			case OV2_Infra:
-				return fmt.Sprintf(".harness/orgs/%s/projects/%s/overrides/%s/infras/%s/overrides.yaml",
-					string(p.OrgIdentifier), p.Identifier, ov.EnvironmentRef, ov.InfraIdentifier)
+				return fmt.Sprintf("%s/overrides/%s/infras/%s/overrides.yaml", gitXScope(p), ov.EnvironmentRef, ov.InfraIdentifier)
 			case OV2_ServiceInfra:
-				return fmt.Sprintf(".harness/orgs/%s/projects/%s/overrides/%s/services/%s/infras/%s/overrides.yaml",
-					string(p.OrgIdentifier), p.Identifier, ov.EnvironmentRef, ov.ServiceRef, ov.InfraIdentifier)
+				return fmt.Sprintf("%s/overrides/%s/services/%s/infras/%s/overrides.yaml", gitXScope(p), ov.EnvironmentRef, ov.ServiceRef, ov.InfraIdentifier)
 			default:
 				panic(fmt.Sprintf("unrecognized overrides V2 type %s", ov.Type))
 			}
 		}
-		return "overrides/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/" + ov.Identifier + ".yaml"
+		return scopeFolder("overrides", p) + ov.Identifier + ".yaml"
 	} else {
 		return fmt.Sprintf("%s/%s/%s.yaml", customGitDetailsFilePath, GetOverridesLabel(ov), ov.Identifier)
 	}
@@ -120,3 +118,41 @@ func getEnvType(env EnvironmentClass) string {
 		return "unknown"
 	}
 }
+
+// gitXScope returns the GitX folder of the scope p stands for: the account
+// when it has no org, the org when it has no project identifier.
+func gitXScope(p Project) string {
+	switch {
+	case len(p.OrgIdentifier) == 0:
+		return ".harness"
+	case len(p.Identifier) == 0:
+		return fmt.Sprintf(".harness/orgs/%s", p.OrgIdentifier)
+	default:
+		return fmt.Sprintf(".harness/orgs/%s/projects/%s", p.OrgIdentifier, p.Identifier)
+	}
+}
+
+// scopeFolder returns the folder of an entity type in the default layout,
+// nested by org and project for org and project level entities.
+func scopeFolder(entity string, p Project) string {
+	folder := entity + "/"
+	if len(p.OrgIdentifier) > 0 {
+		folder += string(p.OrgIdentifier) + "/"
+	}
+	if len(p.Identifier) > 0 {
+		folder += p.Identifier + "/"
+	}
+	return folder
+}
+
+// GetURLEncodedFilePath returns the path of file in the default layout of the
+// entity type with url encoded slashes, used with -url-encode-string.
+func GetURLEncodedFilePath(entity string, p Project, file string) string {
+	return strings.ReplaceAll(scopeFolder(entity, p), "/", "%2F") + file
+}
+
+// GetCGFilePath returns the path of file in the First Gen folder structure,
+// account/<org>/<project>/<entity>/, used with -alt-path.
+func GetCGFilePath(entity string, p Project, file string) string {
+	return scopeFolder("account", p) + entity + "/" + file
+}
@@ -29,3 +29,31 @@ func Test_GetInfrastructureFilePath_GitX(t *testing.T) {
 	})
 	assert.Equal(t, ".harness/orgs/orgId/projects/pId/envs/pre_production/envId/infras/infraId.yaml", path)
 }
+
+func Test_GetTemplateFilePath_GitXScopes(t *testing.T) {
+	template := Template{Identifier: "tId", VersionLabel: "v1"}
+	assert.Equal(t, ".harness/templates/tId/v1.yaml", GetTemplateFilePath(true, "", Project{}, template))
+	assert.Equal(t, ".harness/orgs/orgId/templates/tId/v1.yaml", GetTemplateFilePath(true, "", Project{OrgIdentifier: "orgId"}, template))
+	assert.Equal(t, ".harness/orgs/orgId/projects/pId/templates/tId/v1.yaml", GetTemplateFilePath(true, "", Project{OrgIdentifier: "orgId", Identifier: "pId"}, template))
+}
+
+func Test_GetServiceFilePath_Scopes(t *testing.T) {
+	service := ServiceClass{Identifier: "sId"}
+	assert.Equal(t, "services/sId.yaml", GetServiceFilePath(false, "", Project{}, service))
+	assert.Equal(t, "services/orgId/sId.yaml", GetServiceFilePath(false, "", Project{OrgIdentifier: "orgId"}, service))
+	assert.Equal(t, "services/orgId/pId/sId.yaml", GetServiceFilePath(false, "", Project{OrgIdentifier: "orgId", Identifier: "pId"}, service))
+}
+
+func Test_URLEncodedFilePath_Scopes(t *testing.T) {
+	file := "tId-v1.yaml"
+	assert.Equal(t, "templates%2FtId-v1.yaml", GetURLEncodedFilePath("templates", Project{}, file))
+	assert.Equal(t, "templates%2ForgId%2FtId-v1.yaml", GetURLEncodedFilePath("templates", Project{OrgIdentifier: "orgId"}, file))
+	assert.Equal(t, "templates%2ForgId%2FpId%2FtId-v1.yaml", GetURLEncodedFilePath("templates", Project{OrgIdentifier: "orgId", Identifier: "pId"}, file))
+}
+
+func Test_CGFilePath_Scopes(t *testing.T) {
+	file := "tId-v1.yaml"
+	assert.Equal(t, "account/templates/tId-v1.yaml", GetCGFilePath("templates", Project{}, file))
+	assert.Equal(t, "account/orgId/templates/tId-v1.yaml", GetCGFilePath("templates", Project{OrgIdentifier: "orgId"}, file))
+	assert.Equal(t, "account/orgId/pId/templates/tId-v1.yaml", GetCGFilePath("templates", Project{OrgIdentifier: "orgId", Identifier: "pId"}, file))
+}
@@ -0,0 +1,68 @@
+package harness
+
+import "strings"
+
+// GitRoute sends the entities it matches to their own git location. Scope is
+// account, an org or org/project, an org also matches the entities of its
+// projects. Types, Tags and EnvironmentType match like filter rules, an empty
+// field matches every entity. The git details that are set replace the ones
+// of gitDetails.
+type GitRoute struct {
+	Scope           string       `yaml:"scope"`
+	Types           []EntityType `yaml:"types"`
+	Tags            Tags         `yaml:"tags"`
+	EnvironmentType string       `yaml:"environment_type"`
+
+	ConnectorRef  string `yaml:"connector_ref"`
+	RepoName      string `yaml:"repo_name"`
+	BranchName    string `yaml:"branch_name"`
+	CommitMessage string `yaml:"commit_message"`
+}
+
+// GitRoute returns the git details of an entity of the scope p stands for,
+// gitDetails updated with the first route that matches the entity. The file
+// path is left to the caller.
+func (c *Config) GitRoute(p Project, s FilterSubject) GitDetails {
+	git := c.GitDetails
+	for _, r := range c.GitRoutes {
+		if !r.matches(p, s) {
+			continue
+		}
+		if len(r.ConnectorRef) > 0 {
+			git.ConnectorRef = r.ConnectorRef
+		}
+		if len(r.RepoName) > 0 {
+			git.RepoName = r.RepoName
+		}
+		if len(r.BranchName) > 0 {
+			git.BranchName = r.BranchName
+		}
+		if len(r.CommitMessage) > 0 {
+			git.CommitMessage = r.CommitMessage
+		}
+		break
+	}
+	return git
+}
+
+func (r GitRoute) matches(p Project, s FilterSubject) bool {
+	if len(r.EnvironmentType) > 0 && r.EnvironmentType != s.EnvironmentType {
+		return false
+	}
+	rule := FilterRule{Types: r.Types, Tags: r.Tags}
+	return r.inScope(p) && rule.appliesTo(s.Type) && rule.matches(s)
+}
+
+func (r GitRoute) inScope(p Project) bool {
+	org := string(p.OrgIdentifier)
+	switch {
+	case len(r.Scope) == 0:
+		return true
+	case r.Scope == AccountScope:
+		return len(org) == 0
+	case strings.Contains(r.Scope, "/"):
+		return len(p.Identifier) > 0 && MatchProject(r.Scope, p)
+	default:
+		return r.Scope == org
+	}
+}
@@ -0,0 +1,36 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_GitRoute(t *testing.T) {
+	c := Config{
+		GitDetails: GitDetails{ConnectorRef: "account.github", RepoName: "harness", BranchName: "migration", CommitMessage: "Migrate"},
+		GitRoutes: []GitRoute{
+			{Types: []EntityType{EnvironmentEntity, InfrastructureEntity}, EnvironmentType: "Production", RepoName: "restricted", BranchName: "main"},
+			{Types: []EntityType{TemplateEntity}, RepoName: "template-library", CommitMessage: "Move templates"},
+			{Scope: "retail/payments", Tags: Tags{"team": ""}, ConnectorRef: "org.payments"},
+			{Scope: "retail", RepoName: "retail"},
+			{Scope: "account", RepoName: "platform"},
+		},
+	}
+	payments := Project{OrgIdentifier: "retail", Identifier: "payments"}
+
+	// Routes are matched in order, the first match wins
+	assert.Equal(t, GitDetails{ConnectorRef: "account.github", RepoName: "restricted", BranchName: "main", CommitMessage: "Migrate"},
+		c.GitRoute(payments, FilterSubject{Type: EnvironmentEntity, EnvironmentType: "Production"}))
+	assert.Equal(t, GitDetails{ConnectorRef: "account.github", RepoName: "template-library", BranchName: "migration", CommitMessage: "Move templates"},
+		c.GitRoute(payments, FilterSubject{Type: TemplateEntity}))
+	assert.Equal(t, "org.payments", c.GitRoute(payments, FilterSubject{Type: PipelineEntity, Tags: Tags{"team": "payments"}}).ConnectorRef)
+
+	// Projects of an org use the route of the org
+	assert.Equal(t, "retail", c.GitRoute(payments, FilterSubject{Type: PipelineEntity}).RepoName)
+	assert.Equal(t, "retail", c.GitRoute(Project{OrgIdentifier: "retail"}, FilterSubject{Type: ServiceEntity}).RepoName)
+	assert.Equal(t, "retail", c.GitRoute(payments, FilterSubject{Type: EnvironmentEntity, EnvironmentType: "PreProduction"}).RepoName)
+
+	assert.Equal(t, "platform", c.GitRoute(Project{}, FilterSubject{Type: ServiceEntity}).RepoName)
+	assert.Equal(t, c.GitDetails, c.GitRoute(Project{OrgIdentifier: "wholesale", Identifier: "orders"}, FilterSubject{Type: PipelineEntity}))
+}
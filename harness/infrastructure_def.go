@@ -30,6 +30,7 @@ type Infrastructure struct {
 	ProjectIdentifier string `json:"projectIdentifier"`
 	EnvironmentRef    string `json:"environmentRef"`
 	Name              string `json:"name"`
+	Tags              Tags   `json:"tags"`
 	Type              string `json:"type"`
 	DeploymentType    string `json:"deploymentType"`
 	YAML              string `json:"yaml"`
@@ -19,8 +19,11 @@ type ListInputsetData struct {
 type InputsetContent struct {
 	Identifier            string                `json:"identifier"`
 	Name                  string                `json:"name"`
+	Tags                  Tags                  `json:"tags"`
 	PipelineIdentifier    string                `json:"pipelineIdentifier"`
 	InputSetType          string                `json:"inputSetType"`
 	EntityValidityDetails EntityValidityDetails `json:"entityValidityDetails"`
 	StoreType             string                `json:"storeType"`
+	Version               int64                 `json:"version"`
+	LastUpdatedAt         int64                 `json:"lastUpdatedAt"`
 }
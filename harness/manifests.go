@@ -0,0 +1,497 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitStore is the git location manifests and config files stored in the
+// Harness file store are moved to.
+type GitStore struct {
+	Type         string
+	ConnectorRef string
+	Branch       string
+	// Repos are the stores of the file store scopes pushed to a repo of their
+	// own, manifests reading their files are pointed to them instead.
+	Repos []ScopeStore
+	// Org and Project are the scope of the entity the manifests belong to,
+	// file store references are resolved from it.
+	Org     string
+	Project string
+	// Files holds the downloaded files, references to other files are
+	// reported as missing. Nothing is reported when it is nil.
+	Files FileIndex
+	// Force also points manifests already stored in git to this location.
+	Force bool
+}
+
+// NewGitStore returns the git store of the connector files are moved with.
+func NewGitStore(conn ConnectorClass, git GitDetails, files FileIndex, force bool) GitStore {
+	return GitStore{
+		Type:         GetServiceManifestStoreType(conn.Type),
+		ConnectorRef: git.ConnectorRef,
+		Branch:       git.BranchName,
+		Files:        files,
+		Force:        force,
+	}
+}
+
+// ScopeStore is the git store of a file store scope pushed to a repo of its
+// own, see FileStoreConfig.Repos.
+type ScopeStore struct {
+	FileStoreTarget
+	Type string
+}
+
+// NewScopeStore returns the store of the target, read with conn.
+func NewScopeStore(conn ConnectorClass, t FileStoreTarget) ScopeStore {
+	return ScopeStore{FileStoreTarget: t, Type: GetServiceManifestStoreType(conn.Type)}
+}
+
+// In returns the store used for the manifests of an entity in org and
+// project.
+func (s GitStore) In(org, project string) GitStore {
+	s.Org, s.Project = org, project
+	return s
+}
+
+// repoPath resolves a file store reference to its path in the pushed repo and
+// reports whether the file was downloaded.
+func (s GitStore) repoPath(ref string) (string, bool) {
+	r := ParseFileStoreRef(ref, s.Org, s.Project)
+	return r.RepoPath(), s.Files == nil || s.Files.Contains(r)
+}
+
+// at returns the store of the repo the file store of ref is pushed to.
+func (s GitStore) at(ref FileStoreRef) GitStore {
+	best := 0
+	for _, r := range s.Repos {
+		if m := r.matches(ref.Org, ref.Project); m > best {
+			s.Type, s.ConnectorRef, s.Branch, best = r.Type, r.ConnectorRef, r.Branch, m
+		}
+	}
+	return s
+}
+
+// storeOf returns the store of the repo the referenced files are pushed to.
+// It reports false when they are pushed to different repos.
+func (s GitStore) storeOf(refs []string) (GitStore, bool) {
+	store := s
+	for i, ref := range refs {
+		at := s.at(ParseFileStoreRef(ref, s.Org, s.Project))
+		if i > 0 && (at.ConnectorRef != store.ConnectorRef || at.Branch != store.Branch) {
+			return s, false
+		}
+		store = at
+	}
+	return store, true
+}
+
+// ManifestUpdate is the outcome of rewriting the store of a single manifest
+// or config file. Missing lists the file store references that point to files
+// that were not downloaded. SecretFiles lists the encrypted files that keep a
+// config file in the Harness file store. Location is the YAML path of
+// references found in pipelines and templates. Unsupported marks references
+// of fields that cannot read from git and MixedRepos manifests whose files are
+// pushed to different repos, their Paths are the file store references.
+type ManifestUpdate struct {
+	Identifier  string
+	Type        string
+	Location    string
+	Moved       bool
+	Unsupported bool
+	MixedRepos  bool
+	Paths       []string
+	Missing     []string
+	SecretFiles []string
+}
+
+// manifestLayout describes where a manifest type keeps file store paths.
+// Folder types take a single folderPath in git instead of a list of paths,
+// listKeys and pathKeys are the keys of the manifest spec that hold lists of
+// paths and single paths to other file store files.
+type manifestLayout struct {
+	folder   bool
+	listKeys []string
+	pathKeys []string
+}
+
+// ConfigFileType is the layout used for config files, they have no type of
+// their own.
+const ConfigFileType = "ConfigFile"
+
+var defaultManifestLayout = manifestLayout{listKeys: []string{"valuesPaths"}}
+
+var manifestLayouts = map[string]manifestLayout{
+	"K8sManifest":                      {listKeys: []string{"valuesPaths"}},
+	"Values":                           {},
+	"HelmChart":                        {folder: true, listKeys: []string{"valuesPaths"}},
+	"Kustomize":                        {folder: true},
+	"KustomizePatches":                 {},
+	"OpenshiftTemplate":                {listKeys: []string{"paramsPaths"}},
+	"OpenshiftParam":                   {},
+	"EcsTaskDefinition":                {},
+	"EcsServiceDefinition":             {},
+	"EcsScalingPolicyDefinition":       {},
+	"EcsScalableTargetDefinition":      {},
+	"ServerlessAwsLambda":              {pathKeys: []string{"configOverridePath"}},
+	"TasManifest":                      {listKeys: []string{"varsPaths", "autoScalerPath"}},
+	"TasVars":                          {},
+	"TasAutoScaler":                    {},
+	"AsgLaunchTemplate":                {},
+	"AsgConfiguration":                 {},
+	"AsgScalingPolicy":                 {},
+	"AsgScheduledUpdateGroupAction":    {},
+	"GoogleCloudFunctionDefinition":    {},
+	"AwsLambdaFunctionDefinition":      {},
+	"AwsLambdaFunctionAliasDefinition": {},
+	"DeploymentRepo":                   {},
+	"ReleaseRepo":                      {},
+	ConfigFileType:                     {},
+}
+
+func layoutOf(manifestType string) manifestLayout {
+	if layout, ok := manifestLayouts[manifestType]; ok {
+		return layout
+	}
+	return defaultManifestLayout
+}
+
+// MoveManifestsToGit rewrites the manifests and config files of the service
+// YAML that are stored in the Harness file store to use the git store.
+func (s *ServiceClass) MoveManifestsToGit(store GitStore) ([]ManifestUpdate, error) {
+	updated, updates, err := RewriteManifestStores(s.YAML, store, "service", "serviceDefinition", "spec")
+	if err != nil {
+		return nil, err
+	}
+	s.YAML = updated
+	return updates, nil
+}
+
+// MoveManifestsToGit rewrites the manifests and config files of the service
+// override YAML that are stored in the Harness file store to use the git
+// store.
+func (o *ServiceOverrideContent) MoveManifestsToGit(store GitStore) ([]ManifestUpdate, error) {
+	updated, updates, err := RewriteManifestStores(o.YAML, store, "serviceOverrides")
+	if err != nil {
+		return nil, err
+	}
+	o.YAML = updated
+	return updates, nil
+}
+
+// MoveManifestsToGit rewrites the manifests and config files of the override
+// spec that are stored in the Harness file store to use the git store. The
+// spec is JSON, which is also YAML, and fields are kept the same way.
+func (ov *OverridesV2Content) MoveManifestsToGit(store GitStore) ([]ManifestUpdate, error) {
+	if len(ov.Spec) == 0 {
+		return nil, nil
+	}
+	var spec yaml.Node
+	if err := yaml.Unmarshal(ov.Spec, &spec); err != nil {
+		return nil, err
+	}
+	if len(spec.Content) == 0 {
+		return nil, nil
+	}
+
+	updates, moved := rewriteStores(spec.Content[0], store)
+	if !moved {
+		return updates, nil
+	}
+	var decoded interface{}
+	if err := spec.Content[0].Decode(&decoded); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, err
+	}
+	ov.Spec = data
+	return updates, nil
+}
+
+// RewriteManifestStores points the stores of the manifests and config files
+// listed in the mapping at path to the git store. The YAML is edited as a node
+// tree, so only the store keys change while key order, comments and fields
+// that are not touched are kept. The YAML is returned as is when nothing is
+// moved.
+func RewriteManifestStores(src string, store GitStore, path ...string) (string, []ManifestUpdate, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return src, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return src, nil, nil
+	}
+
+	updates, moved := rewriteStores(mappingPath(doc.Content[0], path...), store)
+	if !moved {
+		return src, updates, nil
+	}
+
+	out, err := encodeYAML(&doc)
+	if err != nil {
+		return src, nil, err
+	}
+	return out, updates, nil
+}
+
+// encodeYAML encodes a document with the two space indent Harness uses.
+func encodeYAML(doc *yaml.Node) (string, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// rewriteStores rewrites the manifests and configFiles lists of a spec.
+func rewriteStores(spec *yaml.Node, store GitStore) ([]ManifestUpdate, bool) {
+	var updates []ManifestUpdate
+	moved := false
+	lists := []struct{ key, item string }{{"manifests", "manifest"}, {"configFiles", "configFile"}}
+	for _, list := range lists {
+		items := mappingValue(spec, list.key)
+		if items == nil || items.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range items.Content {
+			node := mappingValue(item, list.item)
+			if node == nil {
+				continue
+			}
+			manifestType := scalarValue(mappingValue(node, "type"))
+			if list.item == "configFile" {
+				manifestType = ConfigFileType
+			}
+			update := RewriteStoreNode(node, manifestType, store)
+			moved = moved || update.Moved
+			updates = append(updates, update)
+		}
+	}
+	return updates, moved
+}
+
+// RewriteStoreNode points the store of a manifest or config file node to git
+// when it is stored in the Harness file store, or always with Force. Config
+// files with secret files are not moved. File
+// store paths are moved under the prefix following the layout of the
+// manifest type, paths already in git are kept.
+func RewriteStoreNode(node *yaml.Node, manifestType string, store GitStore) ManifestUpdate {
+	update := ManifestUpdate{Identifier: scalarValue(mappingValue(node, "identifier")), Type: manifestType}
+	rewriteStore(mappingValue(node, "spec"), layoutOf(manifestType), store, &update)
+	return update
+}
+
+// rewriteStore points the store kept under the store key of spec to git and
+// records the outcome in update.
+func rewriteStore(spec *yaml.Node, layout manifestLayout, store GitStore, update *ManifestUpdate) {
+	storeNode := mappingValue(spec, "store")
+	if storeNode == nil {
+		return
+	}
+	fromFileStore := isHarnessStore(storeNode)
+	if !fromFileStore && !store.Force {
+		return
+	}
+	// Secret files are references to encrypted files, git stores cannot hold
+	// them so the config file is left in the file store
+	if secrets := sequenceValues(mappingPath(storeNode, "spec", "secretFiles")); fromFileStore && len(secrets) > 0 {
+		update.SecretFiles = secrets
+		return
+	}
+	// The manifest is read from the repo its files are pushed to
+	if fromFileStore {
+		refs := storeRefs(spec, storeNode, layout)
+		var ok bool
+		if store, ok = store.storeOf(refs); !ok {
+			update.MixedRepos = true
+			update.Paths = refs
+			return
+		}
+	}
+
+	setScalar(storeNode, "type", store.Type)
+	storeSpec := mappingValue(storeNode, "spec")
+	if storeSpec == nil || storeSpec.Kind != yaml.MappingNode {
+		storeSpec = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(storeNode, "spec", storeSpec)
+	}
+
+	if files := mappingValue(storeSpec, "files"); files != nil {
+		for _, f := range sequenceValues(files) {
+			update.Paths = append(update.Paths, update.resolve(store, f))
+		}
+		deleteMappingKey(storeSpec, "files")
+		if layout.folder && len(update.Paths) > 0 {
+			setScalar(storeSpec, "folderPath", update.Paths[0])
+		} else {
+			setMappingValue(storeSpec, "paths", stringsNode(update.Paths))
+		}
+	} else if folder := scalarValue(mappingValue(storeSpec, "folderPath")); len(folder) > 0 {
+		update.Paths = []string{folder}
+	} else {
+		update.Paths = sequenceValues(mappingValue(storeSpec, "paths"))
+	}
+
+	setScalar(storeSpec, "connectorRef", store.ConnectorRef)
+	setScalar(storeSpec, "gitFetchType", "Branch")
+	setScalar(storeSpec, "branch", store.Branch)
+
+	// The other files of the manifest only live in the file store when the
+	// manifest itself does
+	if fromFileStore {
+		for _, keys := range [][]string{layout.listKeys, layout.pathKeys} {
+			for _, key := range keys {
+				update.resolvePaths(mappingValue(spec, key), store)
+			}
+		}
+	}
+
+	update.Moved = true
+}
+
+// storeRefs returns the file store references of a manifest kept in the file
+// store, its files and the other files of its layout.
+func storeRefs(spec, storeNode *yaml.Node, layout manifestLayout) []string {
+	refs := sequenceValues(mappingPath(storeNode, "spec", "files"))
+	for _, keys := range [][]string{layout.listKeys, layout.pathKeys} {
+		for _, key := range keys {
+			for _, item := range pathItems(mappingValue(spec, key)) {
+				refs = append(refs, item.Value)
+			}
+		}
+	}
+	return refs
+}
+
+func isHarnessStore(store *yaml.Node) bool {
+	return scalarValue(mappingValue(store, "type")) == "Harness"
+}
+
+// resolve returns the repo path of a file store reference and records it
+// when the file was not downloaded.
+func (u *ManifestUpdate) resolve(store GitStore, ref string) string {
+	repoPath, ok := store.repoPath(ref)
+	if !ok {
+		u.Missing = append(u.Missing, ref)
+	}
+	return repoPath
+}
+
+// resolvePaths resolves a single reference or a list of references. Runtime
+// inputs and expressions are left as they are.
+func (u *ManifestUpdate) resolvePaths(node *yaml.Node, store GitStore) {
+	for _, item := range pathItems(node) {
+		item.Value = u.resolve(store, item.Value)
+	}
+}
+
+// pathItems returns the references of a single reference or a list of
+// references, runtime inputs and expressions excluded.
+func pathItems(node *yaml.Node) []*yaml.Node {
+	if node == nil {
+		return nil
+	}
+	items := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		items = node.Content
+	}
+	var paths []*yaml.Node
+	for _, item := range items {
+		if item.Kind == yaml.ScalarNode && len(item.Value) > 0 && !strings.HasPrefix(item.Value, "<+") {
+			paths = append(paths, item)
+		}
+	}
+	return paths
+}
+
+// mappingPath follows the keys from a mapping node, it returns nil when one
+// of them is missing.
+func mappingPath(node *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		node = mappingValue(node, key)
+	}
+	return node
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces the value of key, or appends the key at the end of
+// the mapping.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// setScalar sets a string value, keeping the style and comments of an
+// existing scalar.
+func setScalar(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil && existing.Kind == yaml.ScalarNode {
+		existing.Tag = "!!str"
+		existing.Value = value
+		return
+	}
+	setMappingValue(node, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func sequenceValues(node *yaml.Node) []string {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var values []string
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			values = append(values, item.Value)
+		}
+	}
+	return values
+}
+
+func stringsNode(values []string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, v := range values {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v})
+	}
+	return node
+}
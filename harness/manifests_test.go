@@ -0,0 +1,346 @@
+package harness
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const serviceManifestsYAML = `service:
+  name: api
+  identifier: api
+  # Deployed by the payments team
+  serviceDefinition:
+    type: Kubernetes
+    spec:
+      manifests:
+        - manifest:
+            identifier: chart
+            type: HelmChart
+            spec:
+              store:
+                type: Harness
+                spec:
+                  files:
+                    - /charts/api
+              valuesPaths:
+                - /values/api.yaml
+              chartName: api
+              subChartPath: charts/sub # not modeled
+              helmVersion: V3
+        - manifest:
+            identifier: remote
+            type: K8sManifest
+            spec:
+              store:
+                type: Github
+                spec:
+                  connectorRef: account.github
+                  gitFetchType: Branch
+                  paths:
+                    - k8s
+                  branch: main
+      artifacts:
+        sidecars:
+          - sidecar:
+              identifier: proxy
+      hooks:
+        - preHook:
+            identifier: fetch
+`
+
+const serviceManifestsMovedYAML = `service:
+  name: api
+  identifier: api
+  # Deployed by the payments team
+  serviceDefinition:
+    type: Kubernetes
+    spec:
+      manifests:
+        - manifest:
+            identifier: chart
+            type: HelmChart
+            spec:
+              store:
+                type: GitLab
+                spec:
+                  folderPath: filestore/org/project/charts/api
+                  connectorRef: org.gitlab
+                  gitFetchType: Branch
+                  branch: migration
+              valuesPaths:
+                - filestore/org/project/values/api.yaml
+              chartName: api
+              subChartPath: charts/sub # not modeled
+              helmVersion: V3
+        - manifest:
+            identifier: remote
+            type: K8sManifest
+            spec:
+              store:
+                type: Github
+                spec:
+                  connectorRef: account.github
+                  gitFetchType: Branch
+                  paths:
+                    - k8s
+                  branch: main
+      artifacts:
+        sidecars:
+          - sidecar:
+              identifier: proxy
+      hooks:
+        - preHook:
+            identifier: fetch
+`
+
+func TestServiceClass_MoveManifestsToGit(t *testing.T) {
+	service := ServiceClass{YAML: serviceManifestsYAML}
+	updates, err := service.MoveManifestsToGit(GitStore{
+		Type:         "GitLab",
+		ConnectorRef: "org.gitlab",
+		Branch:       "migration",
+		Org:          "org",
+		Project:      "project",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestUpdate{
+		{Identifier: "chart", Type: "HelmChart", Moved: true, Paths: []string{"filestore/org/project/charts/api"}},
+		{Identifier: "remote", Type: "K8sManifest"},
+	}, updates)
+	assert.Equal(t, serviceManifestsMovedYAML, service.YAML)
+}
+
+func TestRewriteManifestStores_Unchanged(t *testing.T) {
+	src := "serviceOverrides:\n    environmentRef: dev   # odd indent is kept\n"
+	out, updates, err := RewriteManifestStores(src, GitStore{Type: "Github"}, "serviceOverrides", "manifests")
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+	assert.Equal(t, src, out)
+}
+
+func TestServiceOverrideContent_MoveManifestsToGit_Force(t *testing.T) {
+	override := ServiceOverrideContent{YAML: `serviceOverrides:
+  environmentRef: dev
+  serviceRef: api
+  manifests:
+    - manifest:
+        identifier: values
+        type: Values
+        spec:
+          store:
+            type: Github
+            spec:
+              connectorRef: old
+              paths:
+                - filestore/values.yaml
+              branch: old
+`}
+	updates, err := override.MoveManifestsToGit(GitStore{Type: "Github", ConnectorRef: "new", Branch: "migration", Force: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestUpdate{{Identifier: "values", Type: "Values", Moved: true, Paths: []string{"filestore/values.yaml"}}}, updates)
+	assert.Equal(t, `serviceOverrides:
+  environmentRef: dev
+  serviceRef: api
+  manifests:
+    - manifest:
+        identifier: values
+        type: Values
+        spec:
+          store:
+            type: Github
+            spec:
+              connectorRef: new
+              paths:
+                - filestore/values.yaml
+              branch: migration
+              gitFetchType: Branch
+`, override.YAML)
+}
+
+func TestRewriteManifestStores_Layouts(t *testing.T) {
+	src := `serviceOverrides:
+  manifests:
+    - manifest:
+        identifier: template
+        type: OpenshiftTemplate
+        spec:
+          store:
+            type: Harness
+            spec:
+              files:
+                - /openshift/template.yaml
+          paramsPaths:
+            - /openshift/params.yaml
+            - <+input>
+    - manifest:
+        identifier: lambda
+        type: ServerlessAwsLambda
+        spec:
+          store:
+            type: Harness
+            spec:
+              files:
+                - /serverless
+          configOverridePath: /serverless/override.yaml
+  configFiles:
+    - configFile:
+        identifier: settings
+        spec:
+          store:
+            type: Harness
+            spec:
+              files:
+                - /config/settings.json
+`
+	out, updates, err := RewriteManifestStores(src, GitStore{Type: "Github", ConnectorRef: "github", Branch: "main"}, "serviceOverrides")
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestUpdate{
+		{Identifier: "template", Type: "OpenshiftTemplate", Moved: true, Paths: []string{"filestore/account/openshift/template.yaml"}},
+		{Identifier: "lambda", Type: "ServerlessAwsLambda", Moved: true, Paths: []string{"filestore/account/serverless"}},
+		{Identifier: "settings", Type: ConfigFileType, Moved: true, Paths: []string{"filestore/account/config/settings.json"}},
+	}, updates)
+	assert.Contains(t, out, "            - filestore/account/openshift/params.yaml\n            - <+input>\n")
+	assert.Contains(t, out, "configOverridePath: filestore/account/serverless/override.yaml")
+	assert.NotContains(t, out, "files:")
+}
+
+func TestOverridesV2Content_MoveManifestsToGit(t *testing.T) {
+	override := OverridesV2Content{Spec: []byte(`{"manifests":[{"manifest":{"identifier":"values","type":"Values","spec":{"store":{"type":"Harness","spec":{"files":["/values.yaml"]}}}}}],"variables":[{"name":"replicas","type":"String","value":"2","description":"kept"}]}`)}
+	updates, err := override.MoveManifestsToGit(GitStore{Type: "Github", ConnectorRef: "github", Branch: "main", Org: "org", Project: "project"})
+	assert.NoError(t, err)
+	assert.Len(t, updates, 1)
+	assert.JSONEq(t, `{"manifests":[{"manifest":{"identifier":"values","type":"Values","spec":{"store":{"type":"Github","spec":{"paths":["filestore/org/project/values.yaml"],"connectorRef":"github","gitFetchType":"Branch","branch":"main"}}}}}],"variables":[{"name":"replicas","type":"String","value":"2","description":"kept"}]}`, string(override.Spec))
+}
+
+func TestRewriteManifestStores_ScopedReferences(t *testing.T) {
+	src := `serviceOverrides:
+  manifests:
+    - manifest:
+        identifier: values
+        type: K8sManifest
+        spec:
+          store:
+            type: Harness
+            spec:
+              files:
+                - account:/shared/deployment.yaml
+                - org:/team/service.yaml
+                - /project/configmap.yaml
+          valuesPaths:
+            - org:/team/missing.yaml
+`
+	files := FileIndex{}
+	files.Add("", "", FileStoreContent{Path: "/shared/deployment.yaml"})
+	files.Add("org", "", FileStoreContent{Path: "/team/service.yaml"})
+	files.Add("org", "project", FileStoreContent{Path: "/project/configmap.yaml"})
+
+	store := GitStore{Type: "Github", Files: files}
+	_, updates, err := RewriteManifestStores(src, store.In("org", "project"), "serviceOverrides")
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestUpdate{{
+		Identifier: "values",
+		Type:       "K8sManifest",
+		Moved:      true,
+		Paths:      []string{"filestore/account/shared/deployment.yaml", "filestore/org/team/service.yaml", "filestore/org/project/project/configmap.yaml"},
+		Missing:    []string{"org:/team/missing.yaml"},
+	}}, updates)
+}
+
+func TestRewriteManifestStores_ScopeRepos(t *testing.T) {
+	manifest := func(files ...string) string {
+		return `serviceOverrides:
+  manifests:
+    - manifest:
+        identifier: values
+        type: K8sManifest
+        spec:
+          store:
+            type: Harness
+            spec:
+              files:
+                - ` + strings.Join(files, "\n                - ") + "\n"
+	}
+	store := GitStore{
+		Type:         "Github",
+		ConnectorRef: "account.github",
+		Branch:       "main",
+		Repos: []ScopeStore{
+			{FileStoreTarget: FileStoreTarget{Scope: "org", ConnectorRef: "org.gitlab", Branch: "org-files"}, Type: "GitLab"},
+			{FileStoreTarget: FileStoreTarget{Scope: "org/project", ConnectorRef: "org.project", Branch: "project-files"}, Type: "GitLab"},
+		},
+	}.In("org", "project")
+
+	out, updates, err := RewriteManifestStores(manifest("/project/configmap.yaml"), store, "serviceOverrides")
+	assert.NoError(t, err)
+	assert.True(t, updates[0].Moved)
+	assert.Contains(t, out, "type: GitLab")
+	assert.Contains(t, out, "connectorRef: org.project")
+	assert.Contains(t, out, "branch: project-files")
+
+	out, _, err = RewriteManifestStores(manifest("org:/team/service.yaml"), store, "serviceOverrides")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "connectorRef: org.gitlab")
+	assert.Contains(t, out, "branch: org-files")
+
+	out, _, err = RewriteManifestStores(manifest("account:/shared/deployment.yaml"), store, "serviceOverrides")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "connectorRef: account.github")
+	assert.Contains(t, out, "branch: main")
+
+	// Files pushed to different repos cannot be read from a single store
+	src := manifest("account:/shared/deployment.yaml", "/project/configmap.yaml")
+	out, updates, err = RewriteManifestStores(src, store, "serviceOverrides")
+	assert.NoError(t, err)
+	assert.Equal(t, src, out)
+	assert.Equal(t, []ManifestUpdate{{
+		Identifier: "values",
+		Type:       "K8sManifest",
+		MixedRepos: true,
+		Paths:      []string{"account:/shared/deployment.yaml", "/project/configmap.yaml"},
+	}}, updates)
+}
+
+func TestParseFileStoreRef(t *testing.T) {
+	assert.Equal(t, "filestore/account/a.yaml", ParseFileStoreRef("account:/a.yaml", "org", "project").RepoPath())
+	assert.Equal(t, "filestore/org/a.yaml", ParseFileStoreRef("org:/a.yaml", "org", "project").RepoPath())
+	assert.Equal(t, "filestore/org/project/a.yaml", ParseFileStoreRef("/a.yaml", "org", "project").RepoPath())
+	// References of org level entities point to the org file store
+	assert.Equal(t, "filestore/org/a.yaml", ParseFileStoreRef("/a.yaml", "org", "").RepoPath())
+}
+
+func TestRewriteManifestStores_SecretFiles(t *testing.T) {
+	src := `service:
+  serviceDefinition:
+    type: Ssh
+    spec:
+      configFiles:
+        - configFile:
+            identifier: app
+            spec:
+              store:
+                type: Harness
+                spec:
+                  files:
+                    - /ssh/app.properties
+        - configFile:
+            identifier: credentials
+            spec:
+              store:
+                type: Harness
+                spec:
+                  files:
+                    - /ssh/settings.xml
+                  secretFiles:
+                    - account.ssh_key
+`
+	service := ServiceClass{YAML: src, Org: "org", Project: "project"}
+	updates, err := service.MoveManifestsToGit(GitStore{Type: "Github", ConnectorRef: "github", Branch: "main", Org: "org", Project: "project"})
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestUpdate{
+		{Identifier: "app", Type: ConfigFileType, Moved: true, Paths: []string{"filestore/org/project/ssh/app.properties"}},
+		{Identifier: "credentials", Type: ConfigFileType, SecretFiles: []string{"account.ssh_key"}},
+	}, updates)
+	assert.Contains(t, service.YAML, "                  files:\n                    - /ssh/settings.xml\n                  secretFiles:\n")
+}
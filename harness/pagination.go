@@ -0,0 +1,99 @@
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+// maxListPages guards against endpoints that never report their last page.
+const maxListPages = 10000
+
+// ListTruncatedError is returned together with the items fetched so far when
+// a listing could not be walked to its last page.
+type ListTruncatedError struct {
+	Listing string
+	Pages   int
+	Items   int
+	Err     error
+}
+
+func (e *ListTruncatedError) Error() string {
+	return fmt.Sprintf("%s listing was cut short after %d page(s) and %d item(s) - %s", e.Listing, e.Pages, e.Items, e.Err)
+}
+
+func (e *ListTruncatedError) Unwrap() error {
+	return e.Err
+}
+
+// IsListTruncated reports whether err only means that a listing is incomplete,
+// in which case the items returned alongside it are still valid.
+func IsListTruncated(err error) bool {
+	var truncated *ListTruncatedError
+	return errors.As(err, &truncated)
+}
+
+// pageFetcher fetches a single zero-based page of a listing and reports
+// whether more pages follow it.
+type pageFetcher[T any] func(page int) (items []T, more bool, err error)
+
+// paginate walks every page of a listing. If a page after the first one fails
+// the items collected so far are returned with a *ListTruncatedError.
+func paginate[T any](listing string, fetch pageFetcher[T]) ([]T, error) {
+	var all []T
+	for page := 0; page < maxListPages; page++ {
+		items, more, err := fetch(page)
+		if err != nil {
+			if page == 0 {
+				return nil, err
+			}
+			return all, &ListTruncatedError{Listing: listing, Pages: page, Items: len(all), Err: err}
+		}
+		all = append(all, items...)
+		if !more || len(items) == 0 {
+			return all, nil
+		}
+	}
+
+	return all, &ListTruncatedError{
+		Listing: listing,
+		Pages:   maxListPages,
+		Items:   len(all),
+		Err:     fmt.Errorf("reached the limit of %d pages", maxListPages),
+	}
+}
+
+// morePages is used by NG endpoints that report their page index and the
+// total number of pages in the response body.
+func morePages(pageIndex, totalPages int64) bool {
+	return pageIndex+1 < totalPages
+}
+
+// moreV1Pages is used by v1 endpoints that return a bare JSON array. They
+// report the total in the X-Total-Elements header; when it is missing a full
+// page is taken as a sign that another one may follow.
+func moreV1Pages(resp *resty.Response, page, limit, count int) bool {
+	if total, err := strconv.Atoi(resp.Header().Get("X-Total-Elements")); err == nil {
+		return (page+1)*limit < total
+	}
+	return count == limit
+}
+
+// responseError turns a non-200 response into an error carrying the
+// correlation ID and response messages returned by Harness.
+func responseError(resp *resty.Response) error {
+	return bodyError(resp.StatusCode(), resp.Body())
+}
+
+// bodyError is responseError for bodies that were not kept in the response.
+func bodyError(status int, body []byte) error {
+	ar := ApiResponse{}
+	err := json.Unmarshal(body, &ar)
+	if err != nil {
+		return fmt.Errorf("unexpected status code %d", status)
+	}
+	return newAPIError(status, ar)
+}
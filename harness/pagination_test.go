@@ -0,0 +1,58 @@
+package harness
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Paginate_WalksAllPages(t *testing.T) {
+	items, err := paginate("numbers", func(page int) ([]int, bool, error) {
+		return []int{page * 2, page*2 + 1}, page < 2, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, items)
+}
+
+func Test_Paginate_ReportsTruncation(t *testing.T) {
+	items, err := paginate("numbers", func(page int) ([]int, bool, error) {
+		if page == 1 {
+			return nil, false, errors.New("boom")
+		}
+		return []int{page}, true, nil
+	})
+	assert.Equal(t, []int{0}, items)
+	assert.True(t, IsListTruncated(err))
+}
+
+func Test_Paginate_FirstPageFailure(t *testing.T) {
+	_, err := paginate("numbers", func(page int) ([]int, bool, error) {
+		return nil, false, errors.New("boom")
+	})
+	assert.Error(t, err)
+	assert.False(t, IsListTruncated(err))
+}
+
+func Test_GetAllPipelines_FollowsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "0":
+			w.Write([]byte(`{"data":{"content":[{"identifier":"a"}],"last":false}}`))
+		case "1":
+			w.Write([]byte(`{"data":{"content":[{"identifier":"b"}],"last":true}}`))
+		default:
+			t.Fatalf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	pipelines, err := api.GetAllPipelines("acc", "org", "project")
+	assert.NoError(t, err)
+	assert.Len(t, pipelines.Data.Content, 2)
+	assert.Equal(t, "b", pipelines.Data.Content[1].Identifier)
+}
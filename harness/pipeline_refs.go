@@ -0,0 +1,142 @@
+package harness
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RewriteFileStoreRefs points the Harness file store references of a pipeline
+// or template YAML to the git store. Every store of the Harness type is moved,
+// wherever it appears: Terraform var and config files, CloudFormation
+// templates and parameters, manifests of Kubernetes steps and of inline
+// services. Shell Script file sources cannot read from git and are only
+// reported. Updates of references inside a step carry the step identifier and
+// type. The YAML is returned as is when nothing is moved.
+func RewriteFileStoreRefs(src string, store GitStore) (string, []ManifestUpdate, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return src, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return src, nil, nil
+	}
+
+	scan := refScan{store: store}
+	scan.walk(doc.Content[0], "", "", ManifestUpdate{})
+	if !scan.moved {
+		return src, scan.updates, nil
+	}
+
+	out, err := encodeYAML(&doc)
+	if err != nil {
+		return src, nil, err
+	}
+	return out, scan.updates, nil
+}
+
+// RefsRewrite is the YAML of an inline pipeline or template with its file
+// store references pointed to the git store, the YAML that is committed when
+// the entity is moved.
+type RefsRewrite struct {
+	Src     string
+	YAML    string
+	Updates []ManifestUpdate
+}
+
+// Changed reports whether the rewrite changes the YAML of the entity.
+func (r *RefsRewrite) Changed() bool {
+	return r != nil && r.YAML != r.Src
+}
+
+// RewriteFileStoreRefs points the file store references of the inline
+// pipeline or template of the entry to the git store, without updating the
+// entity. Other entity types have no references to rewrite, nil is returned
+// for them.
+func (e PlanEntry) RewriteFileStoreRefs(api *APIRequest, c Config, store GitStore) (*RefsRewrite, error) {
+	var src string
+	var err error
+	switch e.Type {
+	case PipelineEntity:
+		src, err = api.GetPipelineYAML(c.AccountIdentifier, e.Org, e.Project, e.Identifier)
+	case TemplateEntity:
+		src, err = api.GetTemplateYAML(c.AccountIdentifier, e.Org, e.Project, e.Identifier, e.VersionLabel)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updated, updates, err := RewriteFileStoreRefs(src, store.In(e.Org, e.Project))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse YAML - %w", err)
+	}
+	return &RefsRewrite{Src: src, YAML: updated, Updates: updates}, nil
+}
+
+// UpdateFileStoreRefs stores the rewritten YAML of the pipeline or template in
+// Harness, before the entry is moved. Nothing is done when the rewrite did not
+// change it.
+func (e PlanEntry) UpdateFileStoreRefs(api *APIRequest, c Config, r *RefsRewrite) error {
+	if !r.Changed() {
+		return nil
+	}
+	if e.Type == PipelineEntity {
+		return api.UpdatePipelineYAML(c.AccountIdentifier, e.Org, e.Project, e.Identifier, r.YAML)
+	}
+	return api.UpdateTemplateYAML(c.AccountIdentifier, e.Org, e.Project, e.Identifier, e.VersionLabel, r.YAML)
+}
+
+type refScan struct {
+	store   GitStore
+	updates []ManifestUpdate
+	moved   bool
+}
+
+// walk looks for file store references in node, the value of key at
+// location. owner identifies the step the node belongs to.
+func (s *refScan) walk(node *yaml.Node, key, location string, owner ManifestUpdate) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			s.walk(item, key, fmt.Sprintf("%s[%d]", location, i), owner)
+		}
+	case yaml.MappingNode:
+		if key == "step" {
+			owner = ManifestUpdate{Identifier: scalarValue(mappingValue(node, "identifier")), Type: scalarValue(mappingValue(node, "type"))}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i].Value, node.Content[i+1]
+			at := k
+			if len(location) > 0 {
+				at = location + "." + k
+			}
+			switch {
+			case k == "manifest" && isHarnessStore(mappingPath(v, "spec", "store")):
+				s.add(RewriteStoreNode(v, scalarValue(mappingValue(v, "type")), s.store), owner, at)
+			case k == "store" && isHarnessStore(v):
+				// Terraform config files are a folder in git
+				layout := manifestLayout{folder: key == "configFiles"}
+				update := ManifestUpdate{Identifier: scalarValue(mappingValue(node, "identifier")), Type: key}
+				rewriteStore(node, layout, s.store, &update)
+				s.add(update, owner, at)
+			case k == "source" && isHarnessStore(v):
+				// Shell Script sources are either inline or in the file store
+				file := scalarValue(mappingPath(v, "spec", "file"))
+				s.add(ManifestUpdate{Type: key, Unsupported: true, Paths: []string{file}}, owner, at)
+			default:
+				s.walk(v, k, at, owner)
+			}
+		}
+	}
+}
+
+func (s *refScan) add(update ManifestUpdate, owner ManifestUpdate, location string) {
+	if len(owner.Identifier) > 0 {
+		update.Identifier, update.Type = owner.Identifier, owner.Type
+	}
+	update.Location = location
+	s.moved = s.moved || update.Moved
+	s.updates = append(s.updates, update)
+}
@@ -92,9 +92,6 @@ type ExecutorInfo struct {
 	Username    string      `json:"username"`
 }
 
-type Tags struct {
-}
-
 type Pageable struct {
 	Sort       Sort  `json:"sort"`
 	PageSize   int64 `json:"pageSize"`
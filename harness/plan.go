@@ -0,0 +1,307 @@
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PlanFormatVersion is bumped whenever the plan file layout changes in a way
+// older binaries cannot apply.
+const PlanFormatVersion = 1
+
+// ErrAlreadyRemote is returned by PlanEntry.Move when Harness reports that the
+// entity was moved to remote in the meantime.
+var ErrAlreadyRemote = errors.New("entity is already remote")
+
+type EntityType string
+
+const (
+	PipelineEntity       EntityType = "pipeline"
+	InputSetEntity       EntityType = "inputset"
+	TemplateEntity       EntityType = "template"
+	ServiceEntity        EntityType = "service"
+	EnvironmentEntity    EntityType = "environment"
+	InfrastructureEntity EntityType = "infrastructure"
+	OverridesV2Entity    EntityType = "overrides-v2"
+)
+
+type PlanAction string
+
+const (
+	ActionMove PlanAction = "move"
+	ActionSkip PlanAction = "skip"
+)
+
+// PlanEntry describes a single entity of the migration and where it is going
+// to be stored. It carries every identifier needed to call move-config.
+// Version, LastUpdatedAt and Checksum capture the state of the entity when
+// the plan was made, Checksum is used for entities listed without a version.
+// References lists the entities found in the YAML of the entity.
+type PlanEntry struct {
+	Type           EntityType      `json:"type" yaml:"type"`
+	Org            string          `json:"org,omitempty" yaml:"org,omitempty"`
+	Project        string          `json:"project,omitempty" yaml:"project,omitempty"`
+	Identifier     string          `json:"identifier" yaml:"identifier"`
+	Name           string          `json:"name,omitempty" yaml:"name,omitempty"`
+	VersionLabel   string          `json:"versionLabel,omitempty" yaml:"versionLabel,omitempty"`
+	Pipeline       string          `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+	Environment    string          `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Service        string          `json:"service,omitempty" yaml:"service,omitempty"`
+	Infrastructure string          `json:"infrastructure,omitempty" yaml:"infrastructure,omitempty"`
+	OverrideType   OverridesV2Type `json:"overrideType,omitempty" yaml:"overrideType,omitempty"`
+	StoreType      string          `json:"storeType" yaml:"storeType"`
+	Version        int64           `json:"version,omitempty" yaml:"version,omitempty"`
+	LastUpdatedAt  int64           `json:"lastUpdatedAt,omitempty" yaml:"lastUpdatedAt,omitempty"`
+	Checksum       string          `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	References     []EntityRef     `json:"references,omitempty" yaml:"references,omitempty"`
+	Action         PlanAction      `json:"action" yaml:"action"`
+	Reason         string          `json:"reason,omitempty" yaml:"reason,omitempty"`
+	GitDetails     GitDetails      `json:"gitDetails" yaml:"gitDetails"`
+}
+
+// Key identifies the entity an entry refers to, independently of its state.
+func (e PlanEntry) Key() string {
+	return strings.Join([]string{
+		string(e.Type), e.Org, e.Project, e.Pipeline, e.Environment, e.Service,
+		e.Infrastructure, string(e.OverrideType), e.Identifier, e.VersionLabel,
+	}, "|")
+}
+
+// YAMLChecksum fingerprints the YAML of entities that are listed without a
+// version or last update timestamp.
+func YAMLChecksum(yaml string) string {
+	if len(yaml) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(yaml))
+	return hex.EncodeToString(sum[:])
+}
+
+// Ref returns a human readable reference of the entity, scoped by org and project.
+func (e PlanEntry) Ref() string {
+	ref := e.Identifier
+	switch e.Type {
+	case TemplateEntity:
+		ref = fmt.Sprintf("%s@%s", e.Identifier, e.VersionLabel)
+	case InputSetEntity:
+		ref = fmt.Sprintf("%s/%s", e.Pipeline, e.Identifier)
+	case InfrastructureEntity:
+		ref = fmt.Sprintf("%s/%s", e.Environment, e.Identifier)
+	}
+	if len(e.Project) > 0 {
+		return fmt.Sprintf("%s/%s/%s", e.Org, e.Project, ref)
+	}
+	if len(e.Org) > 0 {
+		return fmt.Sprintf("%s/%s", e.Org, ref)
+	}
+	return ref
+}
+
+// Move calls the move-config endpoint matching the entry type, using the git
+// details resolved for the entry.
+func (e PlanEntry) Move(api *APIRequest, c Config) error {
+	return e.move(api, c, InlineToRemote)
+}
+
+// Rollback moves the entity back inline from the git location of the entry.
+func (e PlanEntry) Rollback(api *APIRequest, c Config) error {
+	return e.move(api, c, RemoteToInline)
+}
+
+func (e PlanEntry) move(api *APIRequest, c Config, moveType MoveConfigType) error {
+	c.GitDetails = e.GitDetails
+
+	switch e.Type {
+	case PipelineEntity:
+		pipeline := PipelineContent{Identifier: e.Identifier}
+		_, err := pipeline.MovePipeline(api, c, e.Org, e.Project, moveType)
+		return err
+	case InputSetEntity:
+		inputset := InputsetContent{Identifier: e.Identifier, PipelineIdentifier: e.Pipeline}
+		return inputset.MoveInputset(api, c, e.Project, e.Org, moveType)
+	case TemplateEntity:
+		template := Template{Identifier: e.Identifier, Org: e.Org, Project: e.Project, VersionLabel: e.VersionLabel}
+		_, err := template.MoveTemplate(api, c, moveType)
+		return err
+	case ServiceEntity:
+		service := ServiceClass{Identifier: e.Identifier, Org: e.Org, Project: e.Project}
+		_, alreadyRemote, err := service.MoveService(api, c, moveType)
+		if err == nil && alreadyRemote {
+			return ErrAlreadyRemote
+		}
+		return err
+	case EnvironmentEntity:
+		env := EnvironmentClass{Identifier: e.Identifier, OrgIdentifier: e.Org, ProjectIdentifier: e.Project}
+		return env.MoveEnvironment(api, c, moveType)
+	case InfrastructureEntity:
+		infra := Infrastructure{Identifier: e.Identifier, OrgIdentifier: e.Org, ProjectIdentifier: e.Project}
+		return infra.MoveInfrastructure(api, c, e.Environment, moveType)
+	case OverridesV2Entity:
+		override := OverridesV2Content{
+			Identifier:        e.Identifier,
+			OrgIdentifier:     e.Org,
+			ProjectIdentifier: e.Project,
+			EnvironmentRef:    e.Environment,
+			ServiceRef:        e.Service,
+			InfraIdentifier:   e.Infrastructure,
+			Type:              e.OverrideType,
+		}
+		return override.Move(api, c, moveType)
+	default:
+		return fmt.Errorf("unsupported entity type %s", e.Type)
+	}
+}
+
+// dependencyRank orders entity types so that every type comes after the types
+// it can reference. It breaks ties between entries without dependencies.
+var dependencyRank = map[EntityType]int{
+	TemplateEntity:       0,
+	ServiceEntity:        1,
+	EnvironmentEntity:    1,
+	InfrastructureEntity: 2,
+	OverridesV2Entity:    3,
+	PipelineEntity:       4,
+	InputSetEntity:       5,
+}
+
+// RollbackOrder returns the entries in reverse dependency order, dependents
+// before the entities they reference.
+func RollbackOrder(entries []PlanEntry) []PlanEntry {
+	ordered := DependencyOrder(entries)
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+	return ordered
+}
+
+// Plan is the ordered list of entities a migration run is going to process.
+type Plan struct {
+	FormatVersion     int         `json:"formatVersion" yaml:"formatVersion"`
+	CreatedAt         time.Time   `json:"createdAt" yaml:"createdAt"`
+	AccountIdentifier string      `json:"accountIdentifier" yaml:"accountIdentifier"`
+	Entries           []PlanEntry `json:"entries" yaml:"entries"`
+}
+
+func NewPlan(account string) *Plan {
+	return &Plan{
+		FormatVersion:     PlanFormatVersion,
+		CreatedAt:         time.Now().UTC(),
+		AccountIdentifier: account,
+	}
+}
+
+// Add appends an entry to the plan. Entries without an action are moved
+// unless they are already remote.
+func (p *Plan) Add(e PlanEntry) {
+	if len(e.Action) == 0 {
+		e.Action = ActionMove
+		if e.StoreType == string(Remote) {
+			e.Action = ActionSkip
+			e.Reason = "already remote"
+		}
+	}
+	p.Entries = append(p.Entries, e)
+}
+
+// Count returns how many entries of the given type the plan holds.
+func (p *Plan) Count(t EntityType) int {
+	count := 0
+	for _, e := range p.Entries {
+		if e.Type == t {
+			count++
+		}
+	}
+	return count
+}
+
+// MoveCount returns how many entries of the given type the plan moves.
+func (p *Plan) MoveCount(t EntityType) int {
+	count := 0
+	for _, e := range p.Entries {
+		if e.Type == t && e.Action == ActionMove {
+			count++
+		}
+	}
+	return count
+}
+
+// Drift compares the entries an approved plan moves with the current state of
+// the account and describes every entity that changed since the plan was made.
+// Entries the checkpoint of an interrupted apply marks as completed are
+// expected to have changed and are left out, checkpoint may be nil.
+func (p *Plan) Drift(current *Plan, checkpoint *Checkpoint) []string {
+	currentEntries := map[string]PlanEntry{}
+	for _, e := range current.Entries {
+		currentEntries[e.Key()] = e
+	}
+
+	var drift []string
+	for _, planned := range p.Entries {
+		if planned.Action != ActionMove || (checkpoint != nil && checkpoint.Completed(planned)) {
+			continue
+		}
+		now, exists := currentEntries[planned.Key()]
+		switch {
+		case !exists:
+			drift = append(drift, fmt.Sprintf("%s [%s] no longer exists", planned.Type, planned.Ref()))
+		case now.StoreType != planned.StoreType:
+			drift = append(drift, fmt.Sprintf("%s [%s] store type changed from %s to %s", planned.Type, planned.Ref(), planned.StoreType, now.StoreType))
+		case now.Version != planned.Version, now.LastUpdatedAt != planned.LastUpdatedAt, now.Checksum != planned.Checksum:
+			drift = append(drift, fmt.Sprintf("%s [%s] was modified since the plan was made", planned.Type, planned.Ref()))
+		}
+	}
+
+	return drift
+}
+
+// WriteFile writes the plan as YAML when path ends in .yaml or .yml and as
+// JSON otherwise.
+func (p *Plan) WriteFile(path string) error {
+	var data []byte
+	var err error
+	if isYAMLFile(path) {
+		data, err = yaml.Marshal(p)
+	} else {
+		data, err = json.MarshalIndent(p, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func ReadPlanFile(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	if isYAMLFile(path) {
+		err = yaml.Unmarshal(data, plan)
+	} else {
+		err = json.Unmarshal(data, plan)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if plan.FormatVersion != PlanFormatVersion {
+		return nil, fmt.Errorf("unsupported plan format version %d, expected %d", plan.FormatVersion, PlanFormatVersion)
+	}
+
+	return plan, nil
+}
+
+func isYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
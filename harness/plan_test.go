@@ -0,0 +1,85 @@
+package harness
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPlan() *Plan {
+	plan := NewPlan("account")
+	plan.Add(PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build", StoreType: "INLINE", Version: 3, LastUpdatedAt: 100})
+	plan.Add(PlanEntry{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api", StoreType: "INLINE", Checksum: YAMLChecksum("service: api")})
+	plan.Add(PlanEntry{Type: TemplateEntity, Org: "default", Project: "p1", Identifier: "step", VersionLabel: "v1", StoreType: "REMOTE"})
+	return plan
+}
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	plan := testPlan()
+	for _, name := range []string{"plan.json", "plan.yaml"} {
+		path := filepath.Join(t.TempDir(), name)
+		assert.NoError(t, plan.WriteFile(path))
+
+		read, err := ReadPlanFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, plan.AccountIdentifier, read.AccountIdentifier)
+		assert.Equal(t, plan.Entries, read.Entries)
+		assert.Empty(t, plan.Drift(read, nil))
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	plan := testPlan()
+	assert.Equal(t, ActionSkip, plan.Entries[2].Action)
+
+	current := testPlan()
+	current.Entries[0].Version = 4
+	current.Entries[1].StoreType = "REMOTE"
+	current.Entries[2].Version = 7
+
+	drift := plan.Drift(current, nil)
+	assert.Len(t, drift, 2)
+	assert.Contains(t, drift[0], "[default/p1/build] was modified")
+	assert.Contains(t, drift[1], "store type changed from INLINE to REMOTE")
+
+	current.Entries = current.Entries[1:]
+	assert.Contains(t, plan.Drift(current, nil)[0], "no longer exists")
+}
+
+func TestPlanDrift_ResumedApply(t *testing.T) {
+	plan := testPlan()
+
+	// The interrupted apply moved the pipeline before it stopped
+	checkpoint, err := OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint.jsonl"), false, false)
+	assert.NoError(t, err)
+	defer checkpoint.Close()
+	assert.NoError(t, checkpoint.Record(plan.Entries[0], nil, false))
+
+	current := testPlan()
+	current.Entries[0].StoreType = "REMOTE"
+	current.Entries[0].Version = 4
+	current.Entries[1].StoreType = "REMOTE"
+
+	assert.Len(t, plan.Drift(current, nil), 2)
+	drift := plan.Drift(current, checkpoint)
+	assert.Len(t, drift, 1)
+	assert.Contains(t, drift[0], "[default/p1/api] store type changed from INLINE to REMOTE")
+}
+
+func TestRollbackOrder(t *testing.T) {
+	entries := []PlanEntry{
+		{Type: TemplateEntity, Identifier: "t1"},
+		{Type: PipelineEntity, Identifier: "p1"},
+		{Type: InputSetEntity, Identifier: "i1"},
+		{Type: ServiceEntity, Identifier: "s1"},
+		{Type: PipelineEntity, Identifier: "p2"},
+		{Type: InfrastructureEntity, Identifier: "inf1"},
+	}
+
+	var order []string
+	for _, e := range RollbackOrder(entries) {
+		order = append(order, e.Identifier)
+	}
+	assert.Equal(t, []string{"i1", "p2", "p1", "inf1", "s1", "t1"}, order)
+}
@@ -0,0 +1,391 @@
+package harness
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ReportStatus string
+
+const (
+	StatusSuccess       ReportStatus = "success"
+	StatusFailed        ReportStatus = "failed"
+	StatusSkipped       ReportStatus = "skipped"
+	StatusAlreadyRemote ReportStatus = "already-remote"
+	StatusMismatch      ReportStatus = "mismatch"
+	StatusBlocked       ReportStatus = "blocked"
+)
+
+// ReportRecord is the result of processing a single entity.
+type ReportRecord struct {
+	Type          EntityType      `json:"type"`
+	Scope         string          `json:"scope"`
+	Identifier    string          `json:"identifier"`
+	Name          string          `json:"name,omitempty"`
+	StoreType     string          `json:"storeType"`
+	TargetPath    string          `json:"targetPath,omitempty"`
+	Status        ReportStatus    `json:"status"`
+	Reason        string          `json:"reason,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	CorrelationID string          `json:"correlationId,omitempty"`
+	Metadata      *FileMetadata   `json:"metadata,omitempty"`
+	Findings      []SecretFinding `json:"findings,omitempty"`
+	// RefsRewritten marks pipelines and templates whose file store references
+	// were rewritten in Harness, even when they stayed inline
+	RefsRewritten bool `json:"refsRewritten,omitempty"`
+	// Entry is the plan entry of the entity with the git location it was
+	// moved to, entities moved by a run can be rolled back from its report
+	Entry    *PlanEntry    `json:"entry,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// NewReportRecord fills the record of a plan entry with the outcome of err.
+func NewReportRecord(e PlanEntry, err error, duration time.Duration) ReportRecord {
+	record := ReportRecord{
+		Type:       e.Type,
+		Scope:      entryScope(e),
+		Identifier: e.Identifier,
+		Name:       e.Name,
+		StoreType:  e.StoreType,
+		TargetPath: e.GitDetails.FilePath,
+		Status:     StatusSuccess,
+		Entry:      &e,
+		Duration:   duration,
+	}
+	if e.Type == TemplateEntity {
+		record.Identifier = fmt.Sprintf("%s@%s", e.Identifier, e.VersionLabel)
+	}
+
+	switch {
+	case errors.Is(err, ErrAlreadyRemote):
+		record.Status = StatusAlreadyRemote
+	case errors.Is(err, ErrSecretsFound):
+		record.Status = StatusBlocked
+		record.Error = err.Error()
+	case err != nil:
+		record.Status = StatusFailed
+		record.Error = err.Error()
+		record.CorrelationID = CorrelationID(err)
+	}
+
+	return record
+}
+
+// NewVerifyRecord is the record of verifying a moved entity. Mismatches fail
+// the verification, err is set when the entity could not be fetched.
+func NewVerifyRecord(e PlanEntry, mismatches []string, err error, duration time.Duration) ReportRecord {
+	record := NewReportRecord(e, err, duration)
+	if err == nil && len(mismatches) > 0 {
+		record.Status = StatusMismatch
+		record.Error = strings.Join(mismatches, "; ")
+	}
+	return record
+}
+
+// NewSkippedRecord is the record of a plan entry that was not moved.
+func NewSkippedRecord(e PlanEntry, reason string) ReportRecord {
+	record := NewReportRecord(e, nil, 0)
+	record.Status = StatusSkipped
+	record.Reason = reason
+	return record
+}
+
+// NewFileRecord is the record of syncing a file of the org and project file
+// store to the repo, it carries the metadata of the file.
+func NewFileRecord(org, project string, f FileStoreContent, err error) ReportRecord {
+	metadata := f.Metadata()
+	record := ReportRecord{
+		Type:       FileEntity,
+		Scope:      entryScope(PlanEntry{Org: org, Project: project}),
+		Identifier: f.Identifier,
+		Name:       f.Name,
+		TargetPath: FileStoreRef{Org: org, Project: project, Path: f.Path}.RepoPath(),
+		Status:     StatusSuccess,
+		Metadata:   &metadata,
+	}
+	switch {
+	case errors.Is(err, ErrSecretsFound):
+		record.Status = StatusBlocked
+		record.Error = err.Error()
+	case err != nil:
+		record.Status = StatusFailed
+		record.Error = err.Error()
+		record.CorrelationID = CorrelationID(err)
+	}
+	return record
+}
+
+// NewSkippedFileRecord is the record of a file that was not synced.
+func NewSkippedFileRecord(org, project string, f FileStoreContent, reason string) ReportRecord {
+	record := NewFileRecord(org, project, f, nil)
+	record.Status = StatusSkipped
+	record.Reason = reason
+	return record
+}
+
+// entryScope is the scope the entity lives in, including the parent entity
+// for input sets and infrastructure definitions.
+func entryScope(e PlanEntry) string {
+	scope := "account"
+	if len(e.Org) > 0 {
+		scope = e.Org
+	}
+	if len(e.Project) > 0 {
+		scope += "/" + e.Project
+	}
+	switch e.Type {
+	case InputSetEntity:
+		scope += "/" + e.Pipeline
+	case InfrastructureEntity:
+		scope += "/" + e.Environment
+	}
+	return scope
+}
+
+func (r ReportRecord) MarshalJSON() ([]byte, error) {
+	type record ReportRecord
+	return json.Marshal(struct {
+		record
+		DurationMs int64 `json:"durationMs"`
+	}{record(r), r.Duration.Milliseconds()})
+}
+
+// Report collects one record per processed entity. It is safe for concurrent
+// use. Rollback marks the reports of the rollback command, their records are
+// entities moved back inline.
+type Report struct {
+	mu        sync.Mutex
+	StartedAt time.Time      `json:"startedAt"`
+	Rollback  bool           `json:"rollback,omitempty"`
+	Records   []ReportRecord `json:"records"`
+}
+
+func NewReport() *Report {
+	return &Report{StartedAt: time.Now().UTC()}
+}
+
+// ReadReportFile reads a report written as JSON.
+func ReadReportFile(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// MovedEntries returns the entries of the entities the report records as
+// moved, successfully or found already remote, once each in the order they
+// were recorded.
+func (r *Report) MovedEntries() []PlanEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []PlanEntry
+	seen := map[string]bool{}
+	for _, record := range r.Records {
+		moved := record.Status == StatusSuccess || record.Status == StatusAlreadyRemote
+		if !moved || record.Entry == nil || seen[record.Entry.Key()] {
+			continue
+		}
+		seen[record.Entry.Key()] = true
+		entries = append(entries, *record.Entry)
+	}
+	return entries
+}
+
+func (r *Report) Add(record ReportRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Records = append(r.Records, record)
+}
+
+// Filter returns the records of the given type and status, or of every status
+// when status is empty.
+func (r *Report) Filter(t EntityType, status ReportStatus) []ReportRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var records []ReportRecord
+	for _, record := range r.Records {
+		if record.Type == t && (len(status) == 0 || record.Status == status) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// WriteFile writes the report in the format matching the file extension:
+// .csv, .xml for JUnit XML, and JSON otherwise.
+func (r *Report) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		err = r.WriteCSV(file)
+	case ".xml":
+		err = r.WriteJUnit(file)
+	default:
+		err = r.WriteJSON(file)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var reportCSVHeader = []string{"type", "scope", "identifier", "name", "storeType", "targetPath", "status", "reason", "error", "correlationId", "durationMs", "fileUsage", "secretFindings", "refsRewritten"}
+
+func (r *Report) WriteCSV(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(reportCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range r.Records {
+		var fileUsage string
+		if record.Metadata != nil {
+			fileUsage = record.Metadata.FileUsage
+		}
+		var findings []string
+		for _, f := range record.Findings {
+			findings = append(findings, f.String())
+		}
+		var refsRewritten string
+		if record.RefsRewritten {
+			refsRewritten = "true"
+		}
+		err := writer.Write([]string{
+			string(record.Type), record.Scope, record.Identifier, record.Name, record.StoreType, record.TargetPath,
+			string(record.Status), record.Reason, record.Error, record.CorrelationID, strconv.FormatInt(record.Duration.Milliseconds(), 10),
+			fileUsage, strings.Join(findings, "; "), refsRewritten,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes one test suite per entity type and one test case per
+// entity. Failed, mismatched and blocked entities are failures, skipped and
+// already remote entities are reported as skipped.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	suites := junitTestSuites{}
+	index := map[EntityType]int{}
+	durations := map[EntityType]time.Duration{}
+	for _, record := range r.Records {
+		i, ok := index[record.Type]
+		if !ok {
+			i = len(suites.Suites)
+			index[record.Type] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: string(record.Type)})
+		}
+		suite := &suites.Suites[i]
+
+		testCase := junitTestCase{
+			Name:      record.Identifier,
+			ClassName: record.Scope,
+			Time:      seconds(record.Duration),
+		}
+		switch record.Status {
+		case StatusFailed, StatusMismatch, StatusBlocked:
+			testCase.Failure = &junitMessage{Message: record.Error, Text: fmt.Sprintf("CorrelationId: %s", record.CorrelationID)}
+			suite.Failures++
+			suites.Failures++
+		case StatusSkipped, StatusAlreadyRemote:
+			message := record.Reason
+			if len(message) == 0 {
+				message = string(record.Status)
+			}
+			testCase.Skipped = &junitMessage{Message: message}
+			suite.Skipped++
+			suites.Skipped++
+		}
+		suite.Tests++
+		suites.Tests++
+		suite.Cases = append(suite.Cases, testCase)
+		durations[record.Type] += record.Duration
+	}
+	for t, i := range index {
+		suites.Suites[i].Time = seconds(durations[t])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,93 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testReport() *Report {
+	report := NewReport()
+	pipeline := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build", StoreType: "INLINE", GitDetails: GitDetails{FilePath: ".harness/build.yaml"}}
+	template := PlanEntry{Type: TemplateEntity, Org: "default", Identifier: "step", VersionLabel: "v1", StoreType: "INLINE"}
+	inputset := PlanEntry{Type: InputSetEntity, Org: "default", Project: "p1", Pipeline: "build", Identifier: "prod", StoreType: "REMOTE"}
+
+	report.Add(NewReportRecord(pipeline, nil, 1500*time.Millisecond))
+	apiErr := &APIError{StatusCode: 400, CorrelationID: "abc-123", Message: "invalid repo"}
+	report.Add(NewReportRecord(template, fmt.Errorf("move failed - %w", apiErr), 200*time.Millisecond))
+	report.Add(NewSkippedRecord(inputset, "already remote"))
+	return report
+}
+
+func TestReportRecords(t *testing.T) {
+	report := testReport()
+
+	failed := report.Filter(TemplateEntity, StatusFailed)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, "step@v1", failed[0].Identifier)
+	assert.Equal(t, "default", failed[0].Scope)
+	assert.Equal(t, "abc-123", failed[0].CorrelationID)
+	assert.Equal(t, "default/p1/build", report.Filter(InputSetEntity, "")[0].Scope)
+	assert.Empty(t, report.Filter(ServiceEntity, ""))
+}
+
+func TestReportFormats(t *testing.T) {
+	report := testReport()
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&buf))
+	var decoded struct {
+		Records []map[string]interface{} `json:"records"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded.Records, 3)
+	assert.Equal(t, float64(1500), decoded.Records[0]["durationMs"])
+	assert.Equal(t, ".harness/build.yaml", decoded.Records[0]["targetPath"])
+
+	buf.Reset()
+	assert.NoError(t, report.WriteCSV(&buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, reportCSVHeader, rows[0])
+	assert.Equal(t, []string{"template", "default", "step@v1", "", "INLINE", "", "failed", "", "move failed - CorrelationId: abc-123, Message: invalid repo", "abc-123", "200", "", "", ""}, rows[2])
+
+	buf.Reset()
+	assert.NoError(t, report.WriteJUnit(&buf))
+	junit := buf.String()
+	assert.True(t, strings.HasPrefix(junit, "<?xml"))
+	assert.Contains(t, junit, `<testsuites tests="3" failures="1" skipped="1">`)
+	assert.Contains(t, junit, `<testsuite name="pipeline" tests="1" failures="0" skipped="0" time="1.500">`)
+	assert.Contains(t, junit, `<failure message="move failed - CorrelationId: abc-123, Message: invalid repo">CorrelationId: abc-123</failure>`)
+	assert.Contains(t, junit, `<skipped message="already remote"></skipped>`)
+}
+
+func TestReadReportFile_MovedEntries(t *testing.T) {
+	pipeline := PlanEntry{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build", StoreType: "INLINE", GitDetails: GitDetails{FilePath: ".harness/build.yaml"}}
+	service := PlanEntry{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api"}
+	report := testReport()
+	report.Add(NewVerifyRecord(pipeline, nil, nil, 0))
+	report.Add(NewReportRecord(service, ErrAlreadyRemote, 0))
+	report.Add(NewFileRecord("default", "p1", FileStoreContent{Identifier: "values", Path: "/values.yaml"}, nil))
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	assert.NoError(t, report.WriteFile(path))
+	read, err := ReadReportFile(path)
+	assert.NoError(t, err)
+	assert.False(t, read.Rollback)
+
+	// Only moved entities are returned, verified ones once and already
+	// remote ones too
+	entries := read.MovedEntries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, pipeline.Key(), entries[0].Key())
+	assert.Equal(t, ".harness/build.yaml", entries[0].GitDetails.FilePath)
+	assert.Equal(t, service.Key(), entries[1].Key())
+}
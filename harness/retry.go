@@ -0,0 +1,161 @@
+package harness
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+// ErrRequestBudgetExhausted is returned once an endpoint has used up the
+// number of requests allowed by RetryPolicy.EndpointBudget.
+var ErrRequestBudgetExhausted = errors.New("request budget for endpoint exhausted")
+
+// Logger is the subset of the logrus logger used by the harness package.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+// RetryPolicy controls how failed API calls are retried. Zero values fall
+// back to the defaults of DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts caps the total number of attempts per call, 1 disables retries.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is the base of the exponential backoff with jitter.
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	// MaxBackoff caps a single wait, including waits requested by Retry-After.
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// EndpointBudget caps the requests, retries included, sent to a single
+	// endpoint during the run. Zero means unlimited.
+	EndpointBudget int `yaml:"endpointBudget"`
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	return p
+}
+
+// SetRetryPolicy configures the client to retry connection errors, 429 and
+// 502/503/504 responses. It applies to list and move-config calls alike. A
+// move-config to remote that is retried after the server already applied it
+// fails with an "already remote" message, every Move call returns it as
+// ErrAlreadyRemote. The entity is recorded as already remote, which verify
+// and rollback treat like a move. A retried move back inline that was already
+// applied is still reported as failed.
+func (api *APIRequest) SetRetryPolicy(policy RetryPolicy, log Logger) {
+	policy = policy.withDefaults()
+	budget := &requestBudget{limit: policy.EndpointBudget, used: map[string]int{}}
+
+	api.Client.
+		SetRetryCount(policy.MaxAttempts - 1).
+		SetRetryWaitTime(policy.InitialBackoff).
+		SetRetryMaxWaitTime(policy.MaxBackoff).
+		SetRetryAfter(retryAfter).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if err != nil {
+				return !errors.Is(err, ErrRequestBudgetExhausted)
+			}
+			return resp != nil && retryableStatus(resp.StatusCode())
+		}).
+		AddRetryHook(func(resp *resty.Response, err error) {
+			if resp == nil || resp.Request == nil {
+				return
+			}
+			reason := err
+			if reason == nil {
+				reason = errors.New(resp.Status())
+			}
+			log.Warnf("Retrying %s (attempt %d/%d) - %s", endpointKey(api.BaseURL, resp.Request), resp.Request.Attempt, policy.MaxAttempts, reason)
+		}).
+		OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+			return budget.take(endpointKey(api.BaseURL, r))
+		}).
+		OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			attempt := resp.Request.Attempt
+			final := !retryableStatus(resp.StatusCode()) || attempt >= policy.MaxAttempts
+			if final && attempt > 1 {
+				log.Infof("%s finished with status %d after %d retries", endpointKey(api.BaseURL, resp.Request), resp.StatusCode(), attempt-1)
+			}
+			return nil
+		}).
+		OnError(func(r *resty.Request, err error) {
+			if r.Attempt > 1 {
+				log.Warnf("%s failed after %d retries - %s", endpointKey(api.BaseURL, r), r.Attempt-1, err)
+			}
+		})
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter honours the Retry-After header in both its seconds and HTTP
+// date forms. Returning zero lets resty fall back to its jittered backoff.
+func retryAfter(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+	value := resp.Header().Get("Retry-After")
+	if len(value) == 0 {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), nil
+	}
+	return 0, nil
+}
+
+// endpointKey identifies an endpoint by method and URL template, path
+// parameters are still unresolved when the request middleware runs.
+func endpointKey(baseURL string, r *resty.Request) string {
+	return r.Method + " " + strings.TrimPrefix(r.URL, baseURL)
+}
+
+type requestBudget struct {
+	mu    sync.Mutex
+	limit int
+	used  map[string]int
+}
+
+func (b *requestBudget) take(endpoint string) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used[endpoint] >= b.limit {
+		return ErrRequestBudgetExhausted
+	}
+	b.used[endpoint]++
+	return nil
+}
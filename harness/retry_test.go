@@ -0,0 +1,88 @@
+package harness
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Infof(format string, args ...interface{}) { l.t.Logf(format, args...) }
+func (l testLogger) Warnf(format string, args ...interface{}) { l.t.Logf(format, args...) }
+
+func Test_RetryPolicy_RetriesRateLimitedCalls(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`[{"org":{"identifier":"default"}}]`))
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	api.SetRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, testLogger{t})
+
+	orgs, err := api.GetAllOrgs("acc")
+	assert.NoError(t, err)
+	assert.Len(t, orgs, 1)
+	assert.Equal(t, 3, hits)
+}
+
+func Test_RetryPolicy_EndpointBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	api.SetRetryPolicy(RetryPolicy{EndpointBudget: 1}, testLogger{t})
+
+	_, err := api.GetAllOrgs("acc")
+	assert.NoError(t, err)
+	_, err = api.GetAllOrgs("acc")
+	assert.True(t, errors.Is(err, ErrRequestBudgetExhausted))
+}
+
+func Test_RetryPolicy_ReplayedMoveIsAlreadyRemote(t *testing.T) {
+	entries := []PlanEntry{
+		{Type: PipelineEntity, Org: "default", Project: "p1", Identifier: "build"},
+		{Type: TemplateEntity, Org: "default", Project: "p1", Identifier: "step", VersionLabel: "v1"},
+		{Type: ServiceEntity, Org: "default", Project: "p1", Identifier: "api"},
+		{Type: EnvironmentEntity, Org: "default", Project: "p1", Identifier: "dev"},
+		{Type: InfrastructureEntity, Org: "default", Project: "p1", Identifier: "k8s", Environment: "dev"},
+		{Type: InputSetEntity, Org: "default", Project: "p1", Identifier: "inputs", Pipeline: "build"},
+		{Type: OverridesV2Entity, Org: "default", Project: "p1", Identifier: "dev_api", Environment: "dev", Service: "api", OverrideType: "ENV_SERVICE_OVERRIDE"},
+	}
+	for _, e := range entries {
+		hits := 0
+		// The first call is applied but answered with 503, the retry finds the
+		// entity remote
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			if hits == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"ERROR","code":"INVALID_REQUEST","responseMessages":[{"code":"INVALID_REQUEST","level":"ERROR","message":"Entity ` + e.Identifier + ` is already remote"}]}`))
+		}))
+
+		api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+		api.SetRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, testLogger{t})
+
+		err := e.Move(&api, Config{AccountIdentifier: "acc"})
+		assert.True(t, errors.Is(err, ErrAlreadyRemote), "%s: %v", e.Type, err)
+		assert.Equal(t, 2, hits, e.Type)
+		server.Close()
+	}
+}
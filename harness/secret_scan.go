@@ -0,0 +1,324 @@
+package harness
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrSecretsFound is returned when the secret scan policy blocks a file or an
+// entity.
+var ErrSecretsFound = errors.New("secrets found")
+
+type SecretPolicy string
+
+// Secret scan policies. Warn logs and reports the secrets found, Block keeps
+// the file or entity out of git and Redact commits files without their
+// secrets. Entities cannot be redacted, they are moved as stored in Harness,
+// so Redact blocks them.
+const (
+	SecretPolicyOff    SecretPolicy = "off"
+	SecretPolicyWarn   SecretPolicy = "warn"
+	SecretPolicyBlock  SecretPolicy = "block"
+	SecretPolicyRedact SecretPolicy = "redact"
+)
+
+// RedactedSecret replaces the secrets of redacted files.
+const RedactedSecret = "REDACTED"
+
+// SecretScanConfig scans the downloaded files and the YAML of the entities
+// for secrets before they are committed. Nothing is scanned when Policy is
+// empty or off.
+type SecretScanConfig struct {
+	Policy SecretPolicy `yaml:"policy"`
+	// Rules are scanned for after the default rules, unless
+	// DisableDefaultRules is set
+	Rules               []SecretRule `yaml:"rules"`
+	DisableDefaultRules bool         `yaml:"disableDefaultRules"`
+	// Allow holds regular expressions of secrets that are not reported
+	Allow []string `yaml:"allow"`
+
+	rules []SecretRule
+	allow []*regexp.Regexp
+}
+
+// SecretRule is a regular expression matching a secret. When it has a group
+// named secret only the group is the secret, otherwise the whole match.
+// Entropy is the minimum Shannon entropy, in bits per character, of a secret,
+// it rules out placeholders and plain words.
+type SecretRule struct {
+	Name    string  `yaml:"name"`
+	Pattern string  `yaml:"pattern"`
+	Entropy float64 `yaml:"entropy"`
+
+	regex *regexp.Regexp
+}
+
+// DefaultSecretRules are the rules scanned for unless disabled. Values that
+// are Harness expressions, like <+secrets.getValue("token")>, do not match.
+var DefaultSecretRules = []SecretRule{
+	{Name: "private-key", Pattern: `-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----[\s\S]*?-----END (?:[A-Z]+ )*PRIVATE KEY-----`},
+	{Name: "aws-access-key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "github-token", Pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`},
+	{Name: "slack-token", Pattern: `\bxox[abposr]-[A-Za-z0-9-]{10,}`},
+	{Name: "harness-token", Pattern: `\b(?:pat|sat)\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9]{10,}\.[A-Za-z0-9]{10,}\b`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: "kubeconfig-credentials", Pattern: `(?:client-key-data|client-certificate-data)["']?\s*:\s*["']?(?P<secret>[A-Za-z0-9+/=]{20,})`},
+	// String variables named like a secret, secret variables only hold the
+	// identifier of a Harness secret
+	{Name: "secret-variable", Pattern: `(?i)name:[ \t]*["']?[\w.-]*(?:password|passwd|secret|token|api_?key)[\w.-]*["']?[ \t]*\r?\n(?:[ \t]+(?:type:[ \t]*String|description:.*|required:.*)\r?\n){0,3}[ \t]+value:[ \t]*["']?(?P<secret>[^\s"'<>]{6,})`},
+	{Name: "secret-assignment", Pattern: `(?i)(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|credentials?)["']?[ \t]*[:=][ \t]*["']?(?P<secret>[^\s"'<>]{8,})`, Entropy: 3.5},
+}
+
+// SecretFinding is a secret found in a file or in the YAML of an entity.
+// Secret only keeps the first characters of the secret.
+type SecretFinding struct {
+	Rule   string `json:"rule"`
+	Line   int    `json:"line"`
+	Secret string `json:"secret"`
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s at line %d", f.Rule, f.Line)
+}
+
+// Enabled reports whether anything is scanned.
+func (c *SecretScanConfig) Enabled() bool {
+	return len(c.Policy) > 0 && c.Policy != SecretPolicyOff
+}
+
+// Blocks reports whether entities with secrets are kept inline.
+func (c *SecretScanConfig) Blocks() bool {
+	return c.Policy == SecretPolicyBlock || c.Policy == SecretPolicyRedact
+}
+
+// Compile validates the policy and compiles the rules and the allow list. It
+// has to be called before anything is scanned.
+func (c *SecretScanConfig) Compile() error {
+	switch c.Policy {
+	case "", SecretPolicyOff, SecretPolicyWarn, SecretPolicyBlock, SecretPolicyRedact:
+	default:
+		return fmt.Errorf("invalid secret scan policy %q, use off, warn, block or redact", c.Policy)
+	}
+
+	var rules []SecretRule
+	if !c.DisableDefaultRules {
+		rules = append(rules, DefaultSecretRules...)
+	}
+	rules = append(rules, c.Rules...)
+	c.rules = nil
+	for _, r := range rules {
+		regex, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid secret rule %s pattern %q - %w", r.Name, r.Pattern, err)
+		}
+		r.regex = regex
+		c.rules = append(c.rules, r)
+	}
+
+	c.allow = nil
+	for _, a := range c.Allow {
+		regex, err := regexp.Compile(a)
+		if err != nil {
+			return fmt.Errorf("invalid secret allow pattern %q - %w", a, err)
+		}
+		c.allow = append(c.allow, regex)
+	}
+	return nil
+}
+
+// secretMatch is the position of a secret in the scanned content.
+type secretMatch struct {
+	start, end int
+	finding    SecretFinding
+}
+
+// Scan returns the secrets found in content, in the order they appear.
+func (c *SecretScanConfig) Scan(content string) []SecretFinding {
+	var findings []SecretFinding
+	for _, m := range c.matches(content) {
+		findings = append(findings, m.finding)
+	}
+	return findings
+}
+
+// Redact returns content with every secret replaced by RedactedSecret, and
+// the secrets found.
+func (c *SecretScanConfig) Redact(content string) (string, []SecretFinding) {
+	matches := c.matches(content)
+	var findings []SecretFinding
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		out.WriteString(content[last:m.start])
+		out.WriteString(RedactedSecret)
+		last = m.end
+		findings = append(findings, m.finding)
+	}
+	out.WriteString(content[last:])
+	return out.String(), findings
+}
+
+// ScanFile scans a downloaded file according to the policy. Redact rewrites
+// the file without its secrets, Block deletes the file and returns
+// ErrSecretsFound. Binary files are not scanned.
+func (c *SecretScanConfig) ScanFile(path string) ([]SecretFinding, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, nil
+	}
+
+	switch c.Policy {
+	case SecretPolicyRedact:
+		redacted, findings := c.Redact(string(data))
+		if len(findings) > 0 {
+			if err := os.WriteFile(path, []byte(redacted), 0644); err != nil {
+				return findings, err
+			}
+		}
+		return findings, nil
+	case SecretPolicyBlock:
+		findings := c.Scan(string(data))
+		if len(findings) > 0 {
+			if err := os.Remove(path); err != nil {
+				return findings, err
+			}
+			return findings, SecretsError(findings)
+		}
+		return nil, nil
+	default:
+		return c.Scan(string(data)), nil
+	}
+}
+
+// SecretsError wraps ErrSecretsFound with the findings.
+func SecretsError(findings []SecretFinding) error {
+	var found []string
+	for _, f := range findings {
+		found = append(found, f.String())
+	}
+	return fmt.Errorf("%w - %s", ErrSecretsFound, strings.Join(found, ", "))
+}
+
+// matches returns the secrets of every rule that are not allowed, sorted by
+// position. A secret matched by several rules is only reported by the first.
+func (c *SecretScanConfig) matches(content string) []secretMatch {
+	var matches []secretMatch
+	for _, r := range c.rules {
+		group := r.regex.SubexpIndex("secret")
+		for _, loc := range r.regex.FindAllStringSubmatchIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if group > 0 && loc[2*group] >= 0 {
+				start, end = loc[2*group], loc[2*group+1]
+			}
+			secret := content[start:end]
+			if entropy(secret) < r.Entropy || c.allowed(secret) || overlaps(matches, start, end) {
+				continue
+			}
+			matches = append(matches, secretMatch{
+				start: start,
+				end:   end,
+				finding: SecretFinding{
+					Rule:   r.Name,
+					Line:   strings.Count(content[:start], "\n") + 1,
+					Secret: maskSecret(secret),
+				},
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+func (c *SecretScanConfig) allowed(secret string) bool {
+	for _, a := range c.allow {
+		if a.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(matches []secretMatch, start, end int) bool {
+	for _, m := range matches {
+		if start < m.end && m.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var e float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// maskSecret keeps the first characters of a secret so it can be found
+// without being disclosed.
+func maskSecret(secret string) string {
+	if len(secret) < 12 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+// isBinary reports whether data looks like a binary file.
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// ScanSecrets scans the YAML of the entity as it is stored in Harness. When
+// the policy blocks entities and secrets are found the error wraps
+// ErrSecretsFound.
+func (e PlanEntry) ScanSecrets(api *APIRequest, c Config) ([]SecretFinding, error) {
+	if !c.SecretScan.Enabled() {
+		return nil, nil
+	}
+	entity, err := api.GetInlineEntity(c.AccountIdentifier, e)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get YAML to scan for secrets - %w", err)
+	}
+	return c.SecretScan.ScanEntity(entity.YAML)
+}
+
+// ScanEntity scans the YAML of an entity that is going to be committed, like
+// a pipeline with its file store references rewritten. When the policy blocks
+// entities and secrets are found the error wraps ErrSecretsFound.
+func (c *SecretScanConfig) ScanEntity(yaml string) ([]SecretFinding, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	findings := c.Scan(yaml)
+	if len(findings) > 0 && c.Blocks() {
+		return findings, SecretsError(findings)
+	}
+	return findings, nil
+}
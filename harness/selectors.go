@@ -0,0 +1,93 @@
+package harness
+
+import "strings"
+
+// OrgSelected reports whether projects and entities of the org are migrated
+// according to TargetOrgs and ExcludeOrgs.
+func (c *Config) OrgSelected(org string) bool {
+	if targets := selectors(c.TargetOrgs); len(targets) > 0 {
+		return contains(targets, org)
+	}
+	return !contains(selectors(c.ExcludeOrgs), org)
+}
+
+// ProjectSelected reports whether the project is migrated. Its org has to be
+// selected, then it has to match TargetProjects when they are set or must not
+// match ExcludeProjects. Projects are matched by name or identifier, either
+// alone or qualified with the org as org/project.
+func (c *Config) ProjectSelected(p Project) bool {
+	if !c.OrgSelected(string(p.OrgIdentifier)) {
+		return false
+	}
+	if targets := selectors(c.TargetProjects); len(targets) > 0 {
+		return matchesAny(targets, p)
+	}
+	return !matchesAny(selectors(c.ExcludeProjects), p)
+}
+
+// SelectsOrgs reports whether TargetOrgs or ExcludeOrgs are set.
+func (c *Config) SelectsOrgs() bool {
+	return len(selectors(c.TargetOrgs)) > 0 || len(selectors(c.ExcludeOrgs)) > 0
+}
+
+// SelectsProjects reports whether TargetProjects or ExcludeProjects are set.
+func (c *Config) SelectsProjects() bool {
+	return len(selectors(c.TargetProjects)) > 0 || len(selectors(c.ExcludeProjects)) > 0
+}
+
+// ServiceSelected reports whether the service is migrated according to
+// TargetServices and ExcludeServices. Both map a service identifier to the
+// project it lives in, as a project or as org/project.
+func (c *Config) ServiceSelected(s *ServiceClass) bool {
+	if len(c.TargetServices) > 0 {
+		return matchesService(c.TargetServices, s)
+	}
+	return !matchesService(c.ExcludeServices, s)
+}
+
+// MatchProject reports whether a project or org/project selector refers to p.
+func MatchProject(selector string, p Project) bool {
+	if org, project, ok := strings.Cut(selector, "/"); ok {
+		return org == string(p.OrgIdentifier) && (project == p.Identifier || project == p.Name)
+	}
+	return selector == p.Identifier || selector == p.Name
+}
+
+func matchesAny(selectors []string, p Project) bool {
+	for _, s := range selectors {
+		if MatchProject(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesService(services []map[string]string, s *ServiceClass) bool {
+	p := Project{OrgIdentifier: OrgIdentifier(s.Org), Identifier: s.Project}
+	for _, m := range services {
+		if project, exists := m[s.Identifier]; exists && MatchProject(project, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectors drops the empty entries left by splitting an empty flag.
+func selectors(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
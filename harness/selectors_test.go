@@ -0,0 +1,56 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ProjectSelected_OrgQualified(t *testing.T) {
+	payments := Project{OrgIdentifier: "retail", Identifier: "payments", Name: "Payments"}
+	otherPayments := Project{OrgIdentifier: "banking", Identifier: "payments", Name: "Payments"}
+
+	c := Config{TargetProjects: []string{"retail/payments"}}
+	assert.True(t, c.ProjectSelected(payments))
+	assert.False(t, c.ProjectSelected(otherPayments))
+
+	c = Config{TargetProjects: []string{"Payments"}}
+	assert.True(t, c.ProjectSelected(payments))
+	assert.True(t, c.ProjectSelected(otherPayments))
+
+	c = Config{ExcludeProjects: []string{"banking/payments"}}
+	assert.True(t, c.ProjectSelected(payments))
+	assert.False(t, c.ProjectSelected(otherPayments))
+
+	c = Config{TargetOrgs: []string{"retail"}, TargetProjects: []string{"payments"}}
+	assert.True(t, c.ProjectSelected(payments))
+	assert.False(t, c.ProjectSelected(otherPayments))
+
+	c = Config{ExcludeOrgs: []string{"retail"}}
+	assert.False(t, c.ProjectSelected(payments))
+	assert.True(t, c.ProjectSelected(otherPayments))
+}
+
+func Test_ProjectSelected_EmptySelectors(t *testing.T) {
+	c := Config{TargetProjects: []string{""}, ExcludeProjects: []string{""}, TargetOrgs: []string{""}}
+	assert.True(t, c.ProjectSelected(Project{OrgIdentifier: "retail", Identifier: "payments"}))
+	assert.False(t, c.SelectsOrgs())
+	assert.False(t, c.SelectsProjects())
+}
+
+func Test_ServiceSelected_OrgQualified(t *testing.T) {
+	retail := &ServiceClass{Identifier: "api", Org: "retail", Project: "payments"}
+	banking := &ServiceClass{Identifier: "api", Org: "banking", Project: "payments"}
+
+	c := Config{TargetServices: []map[string]string{{"api": "retail/payments"}}}
+	assert.True(t, c.ServiceSelected(retail))
+	assert.False(t, c.ServiceSelected(banking))
+
+	c = Config{TargetServices: []map[string]string{{"api": "payments"}}}
+	assert.True(t, c.ServiceSelected(retail))
+	assert.True(t, c.ServiceSelected(banking))
+
+	c = Config{ExcludeServices: []map[string]string{{"api": "banking/payments"}, {"web": "payments"}}}
+	assert.True(t, c.ServiceSelected(retail))
+	assert.False(t, c.ServiceSelected(banking))
+}
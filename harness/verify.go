@@ -0,0 +1,189 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RemoteEntity is the stored state of a single entity, as returned by its GET
+// endpoint when it is loaded from the planned branch.
+type RemoteEntity struct {
+	StoreType    string
+	ConnectorRef string
+	RepoName     string
+	Branch       string
+	FilePath     string
+	YAML         string
+}
+
+type remoteGitDetails struct {
+	RepoName string `json:"repoName"`
+	Branch   string `json:"branch"`
+	FilePath string `json:"filePath"`
+}
+
+// remoteEntityData covers the different layouts of the GET responses, the
+// YAML and git details are named differently for every entity type.
+type remoteEntityData struct {
+	YAML             string            `json:"yaml"`
+	YAMLPipeline     string            `json:"yamlPipeline"`
+	InputSetYAML     string            `json:"inputSetYaml"`
+	StoreType        string            `json:"storeType"`
+	ConnectorRef     string            `json:"connectorRef"`
+	GitDetails       *remoteGitDetails `json:"gitDetails"`
+	EntityGitDetails *remoteGitDetails `json:"entityGitDetails"`
+	EntityGitInfo    *remoteGitDetails `json:"entityGitInfo"`
+	Service          *remoteEntityData `json:"service"`
+	Environment      *remoteEntityData `json:"environment"`
+	Infrastructure   *remoteEntityData `json:"infrastructure"`
+}
+
+func (d *remoteEntityData) entity() *RemoteEntity {
+	for _, nested := range []*remoteEntityData{d.Service, d.Environment, d.Infrastructure} {
+		if nested != nil {
+			return nested.entity()
+		}
+	}
+
+	entity := &RemoteEntity{StoreType: d.StoreType, ConnectorRef: d.ConnectorRef}
+	for _, y := range []string{d.YAML, d.YAMLPipeline, d.InputSetYAML} {
+		if len(y) > 0 {
+			entity.YAML = y
+			break
+		}
+	}
+	for _, git := range []*remoteGitDetails{d.GitDetails, d.EntityGitDetails, d.EntityGitInfo} {
+		if git != nil {
+			entity.RepoName = git.RepoName
+			entity.Branch = git.Branch
+			entity.FilePath = git.FilePath
+			break
+		}
+	}
+	return entity
+}
+
+// GetRemoteEntity fetches the entity a plan entry refers to from the branch
+// it was moved to.
+func (api *APIRequest) GetRemoteEntity(account string, e PlanEntry) (*RemoteEntity, error) {
+	return api.getEntity(account, e, map[string]string{
+		"branch":                 e.GitDetails.BranchName,
+		"repoName":               e.GitDetails.RepoName,
+		"loadFromFallbackBranch": "false",
+	})
+}
+
+// GetInlineEntity fetches the entity a plan entry refers to as it is stored
+// in Harness.
+func (api *APIRequest) GetInlineEntity(account string, e PlanEntry) (*RemoteEntity, error) {
+	return api.getEntity(account, e, map[string]string{})
+}
+
+func (api *APIRequest) getEntity(account string, e PlanEntry, params map[string]string) (*RemoteEntity, error) {
+	params["accountIdentifier"] = account
+	params["orgIdentifier"] = e.Org
+	params["projectIdentifier"] = e.Project
+
+	var path string
+	switch e.Type {
+	case PipelineEntity:
+		path = "/pipeline/api/pipelines/{identifier}"
+	case InputSetEntity:
+		path = "/pipeline/api/inputSets/{identifier}"
+		params["pipelineIdentifier"] = e.Pipeline
+	case TemplateEntity:
+		path = "/template/api/templates/{identifier}"
+		params["versionLabel"] = e.VersionLabel
+	case ServiceEntity:
+		path = "/ng/api/servicesV2/{identifier}"
+	case EnvironmentEntity:
+		path = "/ng/api/environmentsV2/{identifier}"
+	case InfrastructureEntity:
+		path = "/ng/api/infrastructures/{identifier}"
+		params["environmentIdentifier"] = e.Environment
+	case OverridesV2Entity:
+		path = "/ng/api/serviceOverrides/{identifier}"
+	default:
+		return nil, fmt.Errorf("unsupported entity type %s", e.Type)
+	}
+
+	resp, err := api.Client.R().
+		SetHeader("x-api-key", api.APIKey).
+		SetHeader("Harness-Account", account).
+		SetQueryParams(omitEmptyScope(params)).
+		SetPathParam("identifier", e.Identifier).
+		Get(api.BaseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != 200 {
+		return nil, responseError(resp)
+	}
+
+	result := struct {
+		Data remoteEntityData `json:"data"`
+	}{}
+	err = json.Unmarshal(resp.Body(), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data.entity(), nil
+}
+
+// Verify checks that the entity is stored in git where the entry planned it
+// and that its YAML loads from there. It returns every mismatch found, the
+// error is only set when the entity could not be fetched.
+func (e PlanEntry) Verify(api *APIRequest, c Config) ([]string, error) {
+	remote, err := api.GetRemoteEntity(c.AccountIdentifier, e)
+	if err != nil {
+		return nil, err
+	}
+	return e.Mismatches(remote), nil
+}
+
+// Mismatches compares a fetched entity with the git details of the entry.
+// A remote entity without repo, branch or file path is reported, a missing
+// connector is not.
+func (e PlanEntry) Mismatches(remote *RemoteEntity) []string {
+	var mismatches []string
+	isRemote := remote.StoreType == string(Remote)
+	if !isRemote {
+		mismatches = append(mismatches, fmt.Sprintf("store type is %s, expected %s", remote.StoreType, Remote))
+	}
+
+	expected := []struct {
+		field, want, got string
+		required         bool
+	}{
+		{"connector", e.GitDetails.ConnectorRef, remote.ConnectorRef, false},
+		{"repo", e.GitDetails.RepoName, remote.RepoName, true},
+		{"branch", e.GitDetails.BranchName, remote.Branch, true},
+		{"file path", strings.TrimPrefix(e.GitDetails.FilePath, "/"), strings.TrimPrefix(remote.FilePath, "/"), true},
+	}
+	for _, x := range expected {
+		switch {
+		case len(x.want) == 0:
+		case len(x.got) == 0:
+			if isRemote && x.required {
+				mismatches = append(mismatches, fmt.Sprintf("%s is missing, expected %s", x.field, x.want))
+			}
+		case x.got != x.want:
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s, expected %s", x.field, x.got, x.want))
+		}
+	}
+
+	if len(strings.TrimSpace(remote.YAML)) == 0 {
+		mismatches = append(mismatches, "remote YAML is empty")
+	} else {
+		var parsed map[string]interface{}
+		if err := yaml.Unmarshal([]byte(remote.YAML), &parsed); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("remote YAML does not load - %s", err))
+		}
+	}
+
+	return mismatches
+}
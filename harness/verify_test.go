@@ -0,0 +1,67 @@
+package harness
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Verify_ReadsEntityFromPlannedBranch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ng/api/servicesV2/api", r.URL.Path)
+		assert.Equal(t, "main", r.URL.Query().Get("branch"))
+		w.Write([]byte(`{"data":{"service":{"storeType":"REMOTE","connectorRef":"account.github","yaml":"service:\n  identifier: api\n",
+			"entityGitDetails":{"repoName":"harness","branch":"main","filePath":"/.harness/services/api.yaml"}}}}`))
+	}))
+	defer server.Close()
+
+	api := APIRequest{BaseURL: server.URL, Client: resty.New()}
+	entry := PlanEntry{
+		Type:       ServiceEntity,
+		Org:        "default",
+		Project:    "p1",
+		Identifier: "api",
+		GitDetails: GitDetails{ConnectorRef: "account.github", RepoName: "harness", BranchName: "main", FilePath: ".harness/services/api.yaml"},
+	}
+
+	mismatches, err := entry.Verify(&api, Config{AccountIdentifier: "acc"})
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func Test_Verify_ReportsMismatches(t *testing.T) {
+	entry := PlanEntry{
+		Type:       PipelineEntity,
+		Identifier: "build",
+		GitDetails: GitDetails{ConnectorRef: "account.github", RepoName: "harness", BranchName: "main", FilePath: ".harness/build.yaml"},
+	}
+
+	mismatches := entry.Mismatches(&RemoteEntity{StoreType: "INLINE", RepoName: "other", Branch: "main", YAML: "pipeline: [broken"})
+	assert.Equal(t, []string{
+		"store type is INLINE, expected REMOTE",
+		"repo is other, expected harness",
+	}, mismatches[:2])
+	assert.Contains(t, mismatches[2], "remote YAML does not load")
+}
+
+func Test_Verify_ReportsMissingGitDetails(t *testing.T) {
+	entry := PlanEntry{
+		Type:       PipelineEntity,
+		Identifier: "build",
+		GitDetails: GitDetails{ConnectorRef: "account.github", RepoName: "harness", BranchName: "main", FilePath: ".harness/build.yaml"},
+	}
+
+	mismatches := entry.Mismatches(&RemoteEntity{StoreType: "REMOTE", YAML: "pipeline:\n  identifier: build\n"})
+	assert.Equal(t, []string{
+		"repo is missing, expected harness",
+		"branch is missing, expected main",
+		"file path is missing, expected .harness/build.yaml",
+	}, mismatches)
+
+	// An inline entity is already reported by its store type
+	mismatches = entry.Mismatches(&RemoteEntity{StoreType: "INLINE", YAML: "pipeline:\n  identifier: build\n"})
+	assert.Equal(t, []string{"store type is INLINE, expected REMOTE"}, mismatches)
+}
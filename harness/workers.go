@@ -0,0 +1,61 @@
+package harness
+
+import "sync"
+
+// ForEach calls fn for every item using at most concurrency goroutines and
+// waits for all of them. fn gets the index of the item so that results can be
+// stored per item instead of being appended to a shared slice.
+func ForEach[T any](items []T, concurrency int, fn func(i int, item T)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i, items[i])
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// BranchLocks serializes work on the same git branch. Git providers reject
+// concurrent commits to a branch, so moves targeting one branch are run one at
+// a time while moves to different branches run in parallel.
+type BranchLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewBranchLocks() *BranchLocks {
+	return &BranchLocks{locks: map[string]*sync.Mutex{}}
+}
+
+// Lock blocks until the branch of the git details is free and returns the
+// function releasing it.
+func (b *BranchLocks) Lock(git GitDetails) func() {
+	key := git.ConnectorRef + "|" + git.RepoName + "|" + git.BranchName
+
+	b.mu.Lock()
+	lock, ok := b.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.locks[key] = lock
+	}
+	b.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
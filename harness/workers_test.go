@@ -0,0 +1,64 @@
+package harness
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ForEach_BoundsConcurrency(t *testing.T) {
+	items := make([]int, 50)
+	results := make([]int, len(items))
+	var running, peak int32
+	ForEach(items, 4, func(i int, _ int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * 2
+		atomic.AddInt32(&running, -1)
+	})
+
+	assert.LessOrEqual(t, peak, int32(4))
+	for i, r := range results {
+		assert.Equal(t, i*2, r)
+	}
+}
+
+func Test_BranchLocks_SerializeSameBranch(t *testing.T) {
+	locks := NewBranchLocks()
+	main := GitDetails{ConnectorRef: "github", RepoName: "repo", BranchName: "main"}
+	other := GitDetails{ConnectorRef: "github", RepoName: "repo", BranchName: "other"}
+
+	var active, overlaps int32
+	ForEach(make([]int, 20), 8, func(i int, _ int) {
+		unlock := locks.Lock(main)
+		defer unlock()
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	})
+	assert.Equal(t, int32(0), overlaps)
+
+	// A different branch is not blocked by a held lock
+	unlock := locks.Lock(main)
+	done := make(chan struct{})
+	go func() {
+		locks.Lock(other)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on another branch was blocked")
+	}
+	unlock()
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aleksa11010/HarnessInlineToRemote/harness"
 	nested "github.com/antonfisher/nested-logrus-formatter"
@@ -13,9 +16,65 @@ import (
 	"github.com/fatih/color"
 	"github.com/go-resty/resty/v2"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
 )
 
+// entityTypes lists the entity types moved by a plan, labelled for summaries.
+var entityTypes = []harness.EntityType{
+	harness.PipelineEntity, harness.InputSetEntity, harness.TemplateEntity, harness.ServiceEntity,
+	harness.EnvironmentEntity, harness.InfrastructureEntity, harness.OverridesV2Entity,
+}
+
+var entityLabels = map[harness.EntityType]string{
+	harness.PipelineEntity:       "pipelines",
+	harness.InputSetEntity:       "input sets",
+	harness.TemplateEntity:       "templates",
+	harness.ServiceEntity:        "services",
+	harness.EnvironmentEntity:    "environments",
+	harness.InfrastructureEntity: "infrastructure definitions",
+	harness.OverridesV2Entity:    "overrides v2",
+}
+
+const moveTmpl = `{{ blue "Moving entities: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
+const rollbackTmpl = `{{ blue "Moving entities inline: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
+const verifyTmpl = `{{ blue "Verifying entities: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
+
+type MigrationScope struct {
+	Pipelines            bool
+	Inputsets            bool
+	Templates            bool
+	Services             bool
+	Environments         bool
+	InfraDef             bool
+	FileStore            bool
+	ServiceManifests     bool
+	ForceUpdateManifests bool
+	Overrides            bool
+	OverridesV2          bool
+	UrlEncoding          bool
+	CGFolderStructure    bool
+	Prod3                bool
+	GitX                 bool
+	CustomRemotePath     string
+	DryRun               bool
+	Concurrency          int
+	AccountLevel         bool
+	OrgLevel             bool
+	ProjectLevel         bool
+	// FileStoreRefs rewrites the file store references of pipelines and
+	// templates before they are moved, FileStoreRefsReportOnly only lists them
+	FileStoreRefs           bool
+	FileStoreRefsReportOnly bool
+	// FileStoreIncremental only downloads the files changed since the last
+	// sync of the workspace and removes the ones deleted from Harness
+	FileStoreIncremental bool
+}
+
+// movesEntities reports whether entities are moved with move-config, the file
+// store, service manifests and overrides are not.
+func (s MigrationScope) movesEntities() bool {
+	return s.Pipelines || s.Inputsets || s.Templates || s.Services || s.Environments || s.InfraDef || s.OverridesV2
+}
+
 func main() {
 
 	log := logrus.New()
@@ -26,6 +85,13 @@ func main() {
 
 	boldCyan := color.New(color.Bold, color.FgBlue)
 
+	// An optional command comes first, flags follow it
+	command := "migrate"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	accountArg := flag.String("account", "", "Provide your account ID.")
 	apiKeyArg := flag.String("api-key", "", "Provide your API Key.")
 	configFile := flag.String("config", "", "Provide a config file.")
@@ -33,6 +99,8 @@ func main() {
 	gitRepoName := flag.String("git-repo-name", "", "Provide a git repo name.")
 	excludeProjects := flag.String("exclude-projects", "", "Provide a list of projects to exclude.")
 	targetProjects := flag.String("target-projects", "", "Provide a list of projects to target.")
+	excludeOrgs := flag.String("exclude-orgs", "", "Provide a list of organizations to exclude.")
+	targetOrgs := flag.String("target-orgs", "", "Provide a list of organizations to target.")
 	allFlag := flag.Bool("all", false, "Migrate all entities.")
 	pipelinesFlag := flag.Bool("pipelines", false, "Migrate pipelines.")
 	inputsetsFlag := flag.Bool("inputsets", false, "Migrate inputsets.")
@@ -50,26 +118,49 @@ func main() {
 	prod3 := flag.Bool("prod3", false, "User Prod3 base URL for API calls")
 	customGitDetailsFilePath := flag.String("custom-remote-path", "", "A custom file path where to save remote manifests.")
 	gitX := flag.Bool("gitx", false, "Migrate entity following the Git Experience definitions")
+	maxAttempts := flag.Int("max-attempts", 0, "Maximum attempts per API call, including retries (default 5).")
+	endpointBudget := flag.Int("endpoint-budget", 0, "Maximum requests sent to a single API endpoint during the run (0 is unlimited).")
+	dryRun := flag.Bool("dry-run", false, "List and plan the migration without moving or updating any entity.")
+	planOutput := flag.String("plan-output", "", "Write the computed migration plan to a JSON or YAML file.")
+	planFile := flag.String("plan", "", "Plan file executed by the apply command or checked by the verify command.")
+	scopesFlag := flag.String("scopes", "project", "Comma separated scopes to migrate entities from: account, org, project.")
+	concurrency := flag.Int("concurrency", 1, "Number of projects listed and entities moved in parallel.")
+	verify := flag.Bool("verify", false, "Verify that every moved entity is stored in git as planned.")
+	checkpointFile := flag.String("checkpoint", "migration-checkpoint.jsonl", "File recording the outcome of every migrated entity.")
+	reportFiles := flag.String("report", "", "Comma separated report files, written as CSV for .csv, JUnit XML for .xml and JSON otherwise.")
+	resume := flag.Bool("resume", false, "Resume an interrupted migration, skipping entities the checkpoint file marks as completed.")
+	rollbackReport := flag.String("rollback-report", "", "JSON report of a previous run whose moved entities the rollback command moves back inline, instead of the checkpoint file.")
+	overwriteCheckpoint := flag.Bool("overwrite-checkpoint", false, "Start the checkpoint file over when it holds the records of a previous run, they can no longer be rolled back.")
+	fileStoreRefsFlag := flag.Bool("filestore-refs", false, "Point file store references of pipelines and templates to the file store repo pushed with -filestore before moving them.")
+	fileStoreRefsReport := flag.Bool("filestore-refs-report-only", false, "Report file store references of pipelines and templates without changing them.")
+	workspaceFlag := flag.String("workspace", "", "Local folder the file store repo is built in (default filestore).")
+	downloadConcurrency := flag.Int("download-concurrency", 0, "Number of file store files downloaded in parallel (default 8).")
+	fileStoreMetadata := flag.Bool("filestore-metadata", false, "Write the metadata of the file store files of every scope to "+harness.FileMetadataFile+".")
+	fileStoreAccount := flag.Bool("filestore-account", false, "Also migrate the file store of the account.")
+	fileStoreAllOrgs := flag.Bool("filestore-all-orgs", false, "Migrate the file store of every org, not only the orgs of the selected projects.")
+	secretScan := flag.String("secret-scan", "", "Scan file store files and entity YAML for secrets before they are committed: off, warn, block or redact.")
+	fileStoreIncremental := flag.Bool("filestore-incremental", false, "Only download file store files changed since the last sync and remove the ones deleted from Harness.")
 
 	flag.Parse()
 
-	type MigrationScope struct {
-		Pipelines            bool
-		Inputsets            bool
-		Templates            bool
-		Services             bool
-		Environments         bool
-		InfraDef             bool
-		FileStore            bool
-		ServiceManifests     bool
-		ForceUpdateManifests bool
-		Overrides            bool
-		OverridesV2          bool
-		UrlEncoding          bool
-		CGFolderStructure    bool
-		Prod3                bool
-		GitX                 bool
+	switch command {
+	case "migrate":
+	case "plan":
+		*dryRun = true
+		if len(*planOutput) == 0 {
+			*planOutput = "migration-plan.json"
+		}
+	case "rollback":
+	case "apply", "verify":
+		if len(*planFile) == 0 {
+			log.Errorf(color.RedString("The %s command needs a plan file, use -plan", command))
+			return
+		}
+	default:
+		log.Errorf(color.RedString("Unknown command %s, use plan, apply, verify, rollback or run without a command to migrate directly", command))
+		return
 	}
+
 	scope := MigrationScope{}
 
 	if *allFlag {
@@ -89,6 +180,9 @@ func main() {
 			CGFolderStructure:    false,
 			Prod3:                false,
 			GitX:                 *gitX,
+			CustomRemotePath:     *customGitDetailsFilePath,
+			DryRun:               *dryRun,
+			Concurrency:          *concurrency,
 		}
 	} else {
 		scope = MigrationScope{
@@ -107,6 +201,27 @@ func main() {
 			CGFolderStructure:    *cgFolderStructure,
 			Prod3:                *prod3,
 			GitX:                 *gitX,
+			CustomRemotePath:     *customGitDetailsFilePath,
+			DryRun:               *dryRun,
+			Concurrency:          *concurrency,
+		}
+	}
+
+	scope.FileStoreRefs = *fileStoreRefsFlag || *fileStoreRefsReport
+	scope.FileStoreRefsReportOnly = *fileStoreRefsReport
+	scope.FileStoreIncremental = *fileStoreIncremental
+
+	for _, level := range strings.Split(*scopesFlag, ",") {
+		switch strings.TrimSpace(level) {
+		case "account":
+			scope.AccountLevel = true
+		case "org":
+			scope.OrgLevel = true
+		case "project":
+			scope.ProjectLevel = true
+		default:
+			log.Errorf(color.RedString("Unknown scope %s, use account, org or project", level))
+			return
 		}
 	}
 
@@ -127,9 +242,39 @@ func main() {
 			},
 			ExcludeProjects: strings.Split(*excludeProjects, ","),
 			TargetProjects:  strings.Split(*targetProjects, ","),
+			ExcludeOrgs:     strings.Split(*excludeOrgs, ","),
+			TargetOrgs:      strings.Split(*targetOrgs, ","),
 		}
 	}
 
+	if len(*workspaceFlag) > 0 {
+		accountConfig.FileStoreConfig.Workspace = *workspaceFlag
+	}
+	if *downloadConcurrency > 0 {
+		accountConfig.FileStoreConfig.DownloadConcurrency = *downloadConcurrency
+	}
+	if *fileStoreMetadata {
+		accountConfig.FileStoreConfig.Metadata = true
+	}
+	if *fileStoreAccount {
+		accountConfig.FileStoreConfig.Scope.AccountFiles = true
+	}
+	if *fileStoreAllOrgs {
+		accountConfig.FileStoreConfig.Scope.AllOrgs = true
+	}
+
+	if err := accountConfig.Filters.Compile(); err != nil {
+		log.Errorf(color.RedString("Invalid filters in config - %s", err))
+		return
+	}
+	if len(*secretScan) > 0 {
+		accountConfig.SecretScan.Policy = harness.SecretPolicy(*secretScan)
+	}
+	if err := accountConfig.SecretScan.Compile(); err != nil {
+		log.Errorf(color.RedString("Invalid secret scan in config - %s", err))
+		return
+	}
+
 	var baseUrl string
 	if scope.Prod3 {
 		baseUrl = harness.BaseURLProd3
@@ -142,6 +287,48 @@ func main() {
 		APIKey:  accountConfig.ApiKey,
 	}
 
+	retryPolicy := accountConfig.Retry
+	if *maxAttempts > 0 {
+		retryPolicy.MaxAttempts = *maxAttempts
+	}
+	if *endpointBudget > 0 {
+		retryPolicy.EndpointBudget = *endpointBudget
+	}
+	api.SetRetryPolicy(retryPolicy, log)
+
+	if command == "verify" {
+		verifyPlan(log, boldCyan, api, accountConfig, *planFile, *reportFiles, scope.Concurrency)
+		return
+	}
+	if command == "rollback" {
+		rollbackCheckpoint(log, boldCyan, api, accountConfig, *checkpointFile, *rollbackReport, *reportFiles, scope.DryRun)
+		return
+	}
+
+	var checkpoint *harness.Checkpoint
+	if !scope.DryRun && (command == "apply" || scope.movesEntities()) {
+		var err error
+		checkpoint, err = harness.OpenCheckpoint(*checkpointFile, *resume, *overwriteCheckpoint)
+		if errors.Is(err, harness.ErrCheckpointExists) {
+			log.Errorf(color.RedString("Checkpoint file %s holds the entities moved by a previous run, they could no longer be rolled back", *checkpointFile))
+			log.Errorf(color.RedString("Use -resume to continue that run, -checkpoint to record this run in another file or -overwrite-checkpoint to start it over"))
+			return
+		}
+		if err != nil {
+			log.Errorf(color.RedString("Unable to open checkpoint file %s - %s", *checkpointFile, err))
+			return
+		}
+		defer checkpoint.Close()
+		if *resume {
+			log.Infof(color.BlueString("Resuming from %s with %d recorded entities", *checkpointFile, checkpoint.Len()))
+		}
+	}
+
+	if command == "apply" {
+		applyPlan(log, boldCyan, api, scope, accountConfig, *planFile, checkpoint, *reportFiles, *verify)
+		return
+	}
+
 	if !scope.Pipelines && !scope.Templates && !scope.FileStore && !scope.Overrides && !scope.Services && !scope.Environments && !scope.InfraDef && !scope.Inputsets && !scope.OverridesV2 {
 		log.Errorf(color.RedString("You need to specify at least one type of entity to migrate!"))
 		log.Errorf(color.RedString("Please use -pipelines, -templates, -services, -environments, -overrides-v2, -filestore or -overrides flags"))
@@ -158,6 +345,7 @@ func main() {
 
 	log.Infof("Getting projects for account %s", accountConfig.AccountIdentifier)
 	projects, err := api.GetAllProjects(accountConfig.AccountIdentifier)
+	err = warnIfTruncated(log, err)
 	if err != nil {
 		log.Errorf(color.RedString("Unable to get projects - %s", err))
 		return
@@ -171,318 +359,208 @@ func main() {
 
 	log.Infof("Filtering projects based on configuration...")
 	var projectList []harness.ProjectsContent
-	if len(accountConfig.TargetProjects) > 0 {
-		for _, project := range projects.Data.Content {
-			skip := true
-			for _, include := range accountConfig.TargetProjects {
-				if project.Project.Name == include || project.Project.Identifier == include {
-					log.Infof(color.BlueString("Project %s is tageted for migration, adding...", project.Project.Name))
-					skip = false
-					break
-				}
-			}
-
-			if skip {
-				continue
-			}
-			projectList = append(projectList, project)
-		}
-	} else if len(accountConfig.ExcludeProjects) > 0 {
-		for _, project := range projects.Data.Content {
-			skip := false
-			for _, exclude := range accountConfig.ExcludeProjects {
-				if project.Project.Name == exclude || project.Project.Identifier == exclude {
-					log.Infof(color.BlueString("Project %s is excluded from migration, skipping...", project.Project.Name))
-					skip = true
-					break
-				}
-			}
-
-			if skip {
-				continue
-			}
-			projectList = append(projectList, project)
+	for _, project := range projects.Data.Content {
+		if !accountConfig.ProjectSelected(project.Project) {
+			log.Infof(color.BlueString("Project %s/%s is not selected for migration, skipping...", project.Project.OrgIdentifier, project.Project.Identifier))
+			continue
 		}
-	} else {
-		projectList = append(projectList, projects.Data.Content...)
+		if accountConfig.SelectsOrgs() || accountConfig.SelectsProjects() {
+			log.Infof(color.BlueString("Project %s/%s is targeted for migration, adding...", project.Project.OrgIdentifier, project.Project.Identifier))
+		}
+		projectList = append(projectList, project)
 	}
 
 	log.Infof("Processing total of %d projects", len(projectList))
-	pipelineTmpl := `{{ blue "Processing Pipelines: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
 	serviceTmpl := `{{ blue "Processing Services: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
-	templateTmpl := `{{ blue "Processing Templates: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
-	servicesTmpl := `{{ blue "Processing Services: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
-	envTmpl := `{{ blue "Processing Environments: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
 	fileTmpl := `{{ blue "Downloading files: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
 	overridesTmpl := `{{ blue "Downloading files: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
 
-	var pipelines []harness.PipelineContent
-	var templates []harness.Template
-	var services []*harness.ServiceClass
-	var environments []*harness.EnvironmentClass
-	var failedPipelines, failedTemplates, failedServices, failedEnvs, alreadyRemoteServices []string
-	for _, project := range projectList {
-		p := project.Project
-		log.Infof(boldCyan.Sprintf("---Processing project %s!---", p.Name))
-		// Get all pipelines for the project
-		if scope.Pipelines {
-			log.Infof("Getting pipelines for project %s", p.Name)
-			projectPipelines, err := api.GetAllPipelines(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get pipelines - %s", err))
-				return
-			}
-			log.Infof(color.BlueString("Found total of %d pipelines", len(projectPipelines.Data.Content)))
-
-			if len(projectPipelines.Data.Content) > 0 {
-				log.Infof("Moving found pipelines to remote")
-				pipelineBar := pb.ProgressBarTemplate(pipelineTmpl).Start(len(projectPipelines.Data.Content))
-				for _, pipeline := range projectPipelines.Data.Content {
-					// Set the directory to pipelines and use the identifier as file name
-					if scope.UrlEncoding {
-						accountConfig.GitDetails.FilePath = "pipelines%2F" + string(p.OrgIdentifier) + "%2F" + p.Identifier + "%2F" + pipeline.Identifier + ".yaml"
-					} else {
-						if scope.CGFolderStructure {
-							accountConfig.GitDetails.FilePath = "account/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/pipelines/" + pipeline.Identifier + ".yaml"
-						} else {
-							accountConfig.GitDetails.FilePath = harness.GetPipelineFilePath(scope.GitX, *customGitDetailsFilePath, p, pipeline)
-						}
-					}
-					_, err := pipeline.MovePipelineToRemote(&api, accountConfig, string(p.OrgIdentifier), p.Identifier)
-					if err != nil {
-						log.Errorf(color.RedString("Unable to move pipeline - %s", pipeline.Name))
-						log.Errorf(color.RedString(err.Error()))
-						failedPipelines = append(failedPipelines, pipeline.Name)
-					}
-					pipelineBar.Increment()
-				}
-				pipelineBar.Finish()
-			}
-			pipelines = append(pipelines, projectPipelines.Data.Content...)
-		}
-		if scope.Inputsets {
-			log.Infof("Getting inputsets for project %s", p.Name)
-			projectPipelines, err := api.GetAllPipelines(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get pipelines for inputsets - %s", err))
-				return
-			}
-
-			if len(projectPipelines.Data.Content) > 0 {
-				for _, pipeline := range projectPipelines.Data.Content {
-					if pipeline.StoreType != "REMOTE" {
-						continue
-					}
-
-					inputsets, err := api.GetInputsets(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, pipeline.Identifier)
-					if err != nil {
-						log.Errorf(color.RedString("Unable to list inputsets from pipeline - %s", pipeline.Name))
-						continue
-					}
-
-					for _, is := range inputsets {
-						accountConfig.GitDetails.FilePath = harness.GetInputsetFilePath(scope.GitX, *customGitDetailsFilePath, p, is)
+	scopeList, err := migrationScopes(log, api, scope, accountConfig, projectList)
+	if err != nil {
+		return
+	}
+	plan, err := buildPlan(log, boldCyan, api, scope, accountConfig, scopeList)
+	if err != nil {
+		return
+	}
 
-						err := is.MoveInputsetToRemote(&api, accountConfig, p.Identifier, string(p.OrgIdentifier))
-						if err != nil {
-							log.Errorf(color.RedString("Unable to move inputsets [%s] for pipeline - %s", is.Name, pipeline.Name))
-							log.Errorf(color.RedString(err.Error()))
+	refs, err := newFileStoreRefs(log, boldCyan, api, scope, accountConfig, scopeList, scope.FileStore)
+	if err != nil {
+		return
+	}
 
-						}
-					}
+	if scope.DryRun {
+		printPlan(log, boldCyan, plan)
+		refs.scan(log, &api, accountConfig, plan)
+	}
+	if len(*planOutput) > 0 {
+		err := plan.WriteFile(*planOutput)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to write migration plan - %s", err))
+		} else {
+			log.Infof(color.GreenString("Migration plan written to %s", *planOutput))
+		}
+	}
+	// The report is written once the file store was synced too
+	report := harness.NewReport()
+	entitiesMoved := false
+	moveEntities := func() {
+		entitiesMoved = true
+		moved := executePlan(log, &api, accountConfig, plan, scope.Concurrency, checkpoint, report, refs)
+		migrationSummary(log, boldCyan, report)
+		if *verify {
+			verifyEntries(log, boldCyan, &api, accountConfig, moved, scope.Concurrency, report)
+		}
+	}
+	// Rewritten file store references point to the pushed files, so the
+	// entities wait for the file store to be pushed
+	rewritesRefs := refs != nil && !refs.reportOnly
+	if !scope.DryRun {
+		defer writeReports(log, report, *reportFiles)
+		if !rewritesRefs {
+			moveEntities()
+		} else {
+			defer func() {
+				if !entitiesMoved {
+					log.Errorf(color.RedString("Entities were not moved, their file store references would point to files that were not pushed"))
 				}
-			}
+			}()
 		}
-
-		if scope.Templates {
-			// Get all templates for the project
-			log.Infof("Getting templates for project %s", project.Project.Name)
-			projectTemplates, err := api.GetAllTemplates(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get templates - %s", err))
-				return
-			}
-			log.Infof(color.BlueString("Found total of %d templates", len(projectTemplates)))
-			if len(projectTemplates) > 0 {
-				log.Infof("Moving found templates to remote")
-				templateBar := pb.ProgressBarTemplate(templateTmpl).Start(len(projectTemplates))
-				for _, template := range projectTemplates {
-					// Set the directory to templates and use the identifier as file name
-					if scope.UrlEncoding {
-						accountConfig.GitDetails.FilePath = "templates%2f" + string(p.OrgIdentifier) + "%2F" + p.Identifier + "%2F" + template.Identifier + "-" + template.VersionLabel + ".yaml"
-						template.GitDetails = accountConfig.GitDetails
-					} else {
-						if scope.CGFolderStructure {
-							accountConfig.GitDetails.FilePath = "account/" + string(p.OrgIdentifier) + "/" + p.Identifier + "/templates/" + template.Identifier + "-" + template.VersionLabel + ".yaml"
-							template.GitDetails = accountConfig.GitDetails
-						} else {
-							accountConfig.GitDetails.FilePath = harness.GetTemplateFilePath(scope.GitX, *customGitDetailsFilePath, p, template)
-							template.GitDetails = accountConfig.GitDetails
-						}
+	}
+	if scope.FileStore {
+		var failedFiles, failedOrgFiles, failedProjectFiles, failedServices []string
+		var issues manifestIssues
+		// Manifests are checked against the files that made it to the repo
+		downloaded := harness.FileIndex{}
+		workspaces, err := newFileStoreWorkspaces(accountConfig.FileStoreConfig, scope.FileStoreIncremental)
+		if err != nil {
+			log.Errorf(color.RedString("%s", err))
+			return
+		}
+		// Files are only listed during a dry run, unchanged files are not
+		// downloaded again
+		var mu sync.Mutex
+		// Downloaded files are scanned for secrets before they are recorded,
+		// blocked files are kept out of the repo
+		downloadFile := func(file harness.FileStoreContent, org, project, folder string) (bool, []harness.SecretFinding, error) {
+			ws := workspaces.of(org, project)
+			unchanged := ws.fileSync.Unchanged(org, project, file)
+			var findings []harness.SecretFinding
+			if !unchanged {
+				workspaces.changed(ws)
+				if scope.DryRun {
+					ws.fileSync.Keep(org, project, file)
+				} else {
+					dir := filepath.Join(ws.Workspace, harness.FileStoreRoot, folder)
+					if err := file.DownloadFile(&api, accountConfig.AccountIdentifier, org, project, dir); err != nil {
+						ws.keep(org, project, file)
+						return false, nil, err
 					}
-					if template.StoreType == "REMOTE" {
-						log.Infof("Template [%s] Version [%s] is already remote!", template.Identifier, template.VersionLabel)
-					} else {
-						_, err := template.MoveTemplateToRemote(&api, accountConfig)
+					if file.Type != harness.FileStoreFolder {
+						var err error
+						local := filepath.Join(ws.Workspace, harness.FileStoreRef{Org: org, Project: project, Path: file.Path}.RepoPath())
+						findings, err = accountConfig.SecretScan.ScanFile(local)
 						if err != nil {
-							log.Errorf(color.RedString("Unable to move template - %s", template.Name))
-							log.Errorf(color.RedString(err.Error()))
-							failedTemplates = append(failedTemplates, template.Name)
+							ws.keep(org, project, file)
+							return false, findings, err
 						}
 					}
-					templateBar.Increment()
-				}
-				templateBar.Finish()
-			}
-			templates = append(templates, projectTemplates...)
-		}
-
-		// SERVICES MOVE TO REMOTE
-		if scope.Services {
-			log.Infof("Getting services for project %s", project.Project.Name)
-			projectServices, err := api.GetServices(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get services - %s", err))
-				return
-			}
-
-			log.Infof(color.BlueString("Found total of %d services", len(projectServices)))
-			if len(projectServices) > 0 {
-				log.Infof("Moving found services to remote")
-				servicesBar := pb.ProgressBarTemplate(servicesTmpl).Start(len(projectServices))
-
-				for _, service := range projectServices {
-					accountConfig.GitDetails.FilePath = harness.GetServiceFilePath(scope.GitX, *customGitDetailsFilePath, p, *service)
-
-					if service.StoreType == "REMOTE" {
-						log.Infof("Service [%s] is already remote", service.Identifier)
-					} else {
-						_, alreadyRemote, err := service.MoveServiceToRemote(&api, accountConfig)
-						if err != nil {
-							log.Errorf(color.RedString("Unable to move service - %s", service.Name))
-							log.Errorf(color.RedString(err.Error()))
-							failedServices = append(failedServices, service.Name)
-						}
-						if alreadyRemote {
-							alreadyRemoteServices = append(alreadyRemoteServices, service.Name)
-						}
+					if err := ws.fileSync.Record(org, project, file); err != nil {
+						return false, findings, err
 					}
-					servicesBar.Increment()
 				}
-				servicesBar.Finish()
 			}
-			services = append(services, projectServices...)
+			ws.metadata.Add(org, project, file)
+			mu.Lock()
+			downloaded.Add(org, project, file)
+			mu.Unlock()
+			return unchanged, findings, nil
 		}
-
-		// ENVIRONMENTS MOVE TO REMOTE
-		if scope.Environments {
-			log.Infof("Getting environments for project %s", project.Project.Name)
-			projectEnvironments, err := api.GetEnvironments(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get environments - %s", err))
-				return
-			}
-
-			log.Infof(color.BlueString("Found total of %d environments", len(projectEnvironments)))
-			if len(projectEnvironments) > 0 {
-				log.Infof("Moving found environments to remote")
-				envBar := pb.ProgressBarTemplate(envTmpl).Start(len(projectEnvironments))
-
-				for _, environment := range projectEnvironments {
-					accountConfig.GitDetails.FilePath = harness.GetEnvironmentFilePath(scope.GitX, *customGitDetailsFilePath, p, *environment)
-
-					if environment.StoreType == "REMOTE" {
-						log.Infof("Environment [%s] is already remote", environment.Identifier)
-					} else {
-						err = environment.MoveEnvironmentToRemote(&api, accountConfig)
-						if err != nil {
-							log.Errorf(color.RedString("Unable to move environment - %s", environment.Name))
-							log.Errorf(color.RedString(err.Error()))
-							failedEnvs = append(failedEnvs, environment.Name)
-						}
-					}
-					envBar.Increment()
+		// downloadFiles downloads the files of a file store in parallel and
+		// returns the names of the ones that failed
+		downloadFiles := func(files []harness.FileStoreContent, org, project, folder string) []string {
+			var failed []string
+			bar := pb.ProgressBarTemplate(fileTmpl).Start(len(files))
+			harness.ForEach(files, accountConfig.FileStoreConfig.DownloadWorkers(), func(_ int, file harness.FileStoreContent) {
+				defer bar.Increment()
+				if excluded(log, accountConfig, file.FilterSubject()) {
+					workspaces.of(org, project).keep(org, project, file)
+					report.Add(harness.NewSkippedFileRecord(org, project, file, "excluded by filters"))
+					return
 				}
-				envBar.Finish()
-			}
-			environments = append(environments, projectEnvironments...)
-		}
-
-		// INFRA-DEF MOVE TO REMOTE
-		if scope.InfraDef {
-			err := processInfraDefScope(log, api, *customGitDetailsFilePath, accountConfig, p, scope.GitX)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to inline-to-remote infrastructure - %s", err))
-			}
+				unchanged, findings, err := downloadFile(file, org, project, folder)
+				switch {
+				case errors.Is(err, harness.ErrSecretsFound):
+					log.Errorf(color.RedString("File [%s] with identifier [%s] is not pushed - %s", file.Name, file.Identifier, err))
+					mu.Lock()
+					failed = append(failed, file.Name)
+					mu.Unlock()
+				case err != nil:
+					log.Errorf(color.RedString("Unable to download file [%s] with identifier [%s] - %s", file.Name, file.Identifier, err))
+					mu.Lock()
+					failed = append(failed, file.Name)
+					mu.Unlock()
+				case unchanged && file.Type != harness.FileStoreFolder:
+					report.Add(harness.NewSkippedFileRecord(org, project, file, "unchanged since the last sync"))
+					return
+				case len(findings) > 0:
+					log.Warnf(color.YellowString("Secrets found in file [%s] with identifier [%s] - %s", file.Name, file.Identifier, harness.SecretsError(findings)))
+				}
+				record := harness.NewFileRecord(org, project, file, err)
+				record.Findings = findings
+				report.Add(record)
+			})
+			bar.Finish()
+			return failed
 		}
 
-		// OVERRIDES-V2 MOVE TO REMOTE
-		if scope.OverridesV2 {
-			err := processOverridesV2(log, api, *customGitDetailsFilePath, accountConfig, p, scope.GitX)
+		var accountFiles []harness.FileStoreContent
+		if accountConfig.FileStoreConfig.Scope.AccountFiles {
+			log.Infof("Getting file store for account %s", accountConfig.AccountIdentifier)
+			accountFiles, err = api.GetAllAccountFiles(accountConfig.AccountIdentifier)
+			err = warnIfTruncated(log, err)
 			if err != nil {
-				log.Errorf(color.RedString("Unable to inline-to-remote overrides v2 - %s", err))
+				log.Errorf(color.RedString("Unable to get file store at account level - %s", err))
+				return
 			}
-		}
-	}
-	if scope.Pipelines {
-		pipelinesSummary(log, boldCyan, failedPipelines, pipelines)
-	}
-	if scope.Templates {
-		templatesSummary(log, boldCyan, failedTemplates, templates)
-	}
-	if scope.Services {
-		servicesSummary(log, boldCyan, failedTemplates, failedServices, alreadyRemoteServices, services)
-	}
-	if scope.Environments {
-		environmentsSummary(log, boldCyan, failedEnvs, environments)
-	}
-	if scope.FileStore {
-		var failedFiles, failedOrgFiles, failedProjectFiles, failedServices []string
-		log.Infof("Getting file store for account %s", accountConfig.AccountIdentifier)
-		accountFiles, err := api.GetAllAccountFiles(accountConfig.AccountIdentifier)
-		if err != nil {
-			log.Errorf(color.RedString("Unable to get file store at account level - %s", err))
-			return
-		}
+			workspaces.listed("", "")
 
-		log.Infof("Downloading %d files from Account level", len(accountFiles))
-		accountFileBar := pb.ProgressBarTemplate(fileTmpl).Start(len(accountFiles))
-		for _, file := range accountFiles {
-			err := file.DownloadFile(&api, accountConfig.AccountIdentifier, "", "", "account")
-			if err != nil {
-				log.Errorf(color.RedString("Unable to download file - %s", err))
-				failedFiles = append(failedFiles, file.Name)
-			}
-			accountFileBar.Increment()
+			log.Infof("Downloading %d files from Account level", len(accountFiles))
+			failedFiles = downloadFiles(accountFiles, "", "", "account")
+		} else {
+			log.Infof(color.BlueString("Skipping file store at account level, use -filestore-account to migrate it"))
 		}
-		accountFileBar.Finish()
 
 		log.Info("Getting file store for organizations")
 		orgs, err := api.GetAllOrgs(accountConfig.AccountIdentifier)
+		err = warnIfTruncated(log, err)
 		if err != nil {
 			log.Errorf(color.RedString("Unable to get organizations for account %s - %s", accountConfig.AccountIdentifier, err))
 			return
 		}
+		// Only the orgs of the selected projects are migrated by default
+		projectOrgs := map[string]bool{}
+		for _, project := range projectList {
+			projectOrgs[string(project.Project.OrgIdentifier)] = true
+		}
 
 		var allOrgFiles []harness.FileStoreContent
 		for _, org := range orgs {
 			o := org.Org
+			if !accountConfig.FileStoreConfig.Scope.AllOrgs && !projectOrgs[o.Identifier] {
+				log.Infof(color.BlueString("Org %s has no selected projects, skipping its file store...", o.Identifier))
+				continue
+			}
 			orgFiles, err := api.GetAllOrgFiles(accountConfig.AccountIdentifier, o.Identifier)
+			err = warnIfTruncated(log, err)
 			if err != nil {
 				log.Errorf(color.RedString("Unable to get file store for org %s - %s", o.Name, err))
+			} else {
+				workspaces.listed(o.Identifier, "")
 			}
 			if len(orgFiles) > 0 {
 				log.Infof("Downloading %d files from Organization %s", len(orgFiles), o.Name)
-				orgFileBar := pb.ProgressBarTemplate(fileTmpl).Start(len(accountFiles))
-				for _, file := range orgFiles {
-					err := file.DownloadFile(&api, accountConfig.AccountIdentifier, o.Identifier, "", "/"+o.Identifier)
-					if err != nil {
-						log.Errorf(color.RedString("Unable to download file - %s", err))
-						failedOrgFiles = append(failedOrgFiles, file.Name)
-					}
-					orgFileBar.Increment()
-				}
-				orgFileBar.Finish()
+				failedOrgFiles = append(failedOrgFiles, downloadFiles(orgFiles, o.Identifier, "", o.Identifier)...)
 			}
 			allOrgFiles = append(allOrgFiles, orgFiles...)
 		}
@@ -492,21 +570,15 @@ func main() {
 		for _, project := range projectList {
 			p := project.Project
 			projectFiles, err := api.GetAllProjectFiles(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+			err = warnIfTruncated(log, err)
 			if err != nil {
 				log.Errorf(color.RedString("Unable to get file store for project %s - %s", p.Name, err))
+			} else {
+				workspaces.listed(string(p.OrgIdentifier), p.Identifier)
 			}
 			if len(projectFiles) > 0 {
 				log.Infof("Downloading %d files from project %s", len(projectFiles), p.Name)
-				projectBar := pb.ProgressBarTemplate(fileTmpl).Start(len(projectFiles))
-				for _, file := range projectFiles {
-					err := file.DownloadFile(&api, accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, fmt.Sprintf("/%s/%s", p.OrgIdentifier, p.Identifier))
-					if err != nil {
-						log.Errorf(color.RedString("Unable to download file [%s] with identifier [%s] - %s", file.Name, file.Identifier, err))
-						failedProjectFiles = append(failedProjectFiles, file.Name)
-					}
-					projectBar.Increment()
-				}
-				projectBar.Finish()
+				failedProjectFiles = append(failedProjectFiles, downloadFiles(projectFiles, string(p.OrgIdentifier), p.Identifier, filepath.Join(string(p.OrgIdentifier), p.Identifier))...)
 			}
 			allProjectFiles = append(allProjectFiles, projectFiles...)
 		}
@@ -524,154 +596,53 @@ func main() {
 			log.Warnf(color.HiYellowString("These files (count:%d) failed while downloading: \n%s", len(failedProjectFiles), strings.Join(failedProjectFiles, ",\n")))
 		}
 
-		log.Infof(boldCyan.Sprintf("---Creating Git Repo---"))
-		var stderr bytes.Buffer
-		// Init empty repo inside the filestore directory
-		cmd := exec.Command("git", "init")
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			log.Errorf(color.RedString("Unable to init git repo - %s", err))
-		}
-
-		// Set pull default to merge
-		cmd = exec.Command("git", "config", "pull.rebase", "false")
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			errorMessage := stderr.String()
-			log.Errorf(color.RedString("Unable to set git pull.rebase to false - Git Operations log:\n %s", errorMessage))
-		}
-
-		log.Infof(color.GreenString("Git repo initialized"))
-		// Add files to git repo
-		cmd = exec.Command("git", "add", ".")
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			log.Errorf(color.RedString("Unable to add files to git repo - Git Operations log:\n %s", err))
-			return
-		}
-		log.Info(color.GreenString("Files added to git repo"))
-
-		// Commit files to git repo
-		cmd = exec.Command("git", "commit", "-m", "Initial Filestore commit")
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			errorMessage := stderr.String()
-			if !strings.Contains(errorMessage, "nothing to commit") {
-				log.Errorf("Unable to commit files to git repo - Git Operations log:\n %s", errorMessage)
+		var backend harness.GitBackend
+		if !scope.DryRun {
+			backend, err = harness.NewGoGitBackend(accountConfig.FileStoreConfig)
+			if err != nil {
+				log.Errorf(color.RedString("Unable to set up git - %s", err))
 				return
 			}
 		}
-		log.Info(color.GreenString("Files committed to git repo"))
-
-		// Set remote url to git repo
-		var url string
-		if accountConfig.FileStoreConfig.RepositoryURL != "" {
-			url = accountConfig.FileStoreConfig.RepositoryURL
-			if !strings.Contains(url, ".git") {
-				url += ".git"
+		// Every repo gets the files of the scopes that were listed for it
+		for _, ws := range workspaces.used() {
+			removed := ws.fileSync.Removed()
+			if scope.FileStoreIncremental {
+				log.Infof(color.GreenString("Incremental sync of %s: %d files changed and %d files deleted since the last sync", ws.Workspace, ws.changedFiles, len(removed)))
 			}
-		} else {
-			var err error
-			conn, err := api.GetConnector(
-				accountConfig.AccountIdentifier,
-				accountConfig.FileStoreConfig.Organization,
-				accountConfig.FileStoreConfig.Project,
-				accountConfig.GitDetails.ConnectorRef,
-			)
-			if err != nil {
-				log.Errorf(color.RedString("Unable to get connector - %s", err))
-				return
+			if scope.DryRun {
+				log.Infof("Dry run: skipping download and push of the file store in %s to branch [%s]", ws.Workspace, ws.Branch)
+				continue
 			}
-			url = conn.Spec.URL + ".git"
-		}
-
-		cmd = exec.Command("git", "remote", "add", "origin", url)
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			errorMessage := stderr.String()
-			if !strings.Contains(errorMessage, "remote origin already exists.") {
-				log.Errorf("Unable to add remote origin to git repo - Git Operations log:\n %s", errorMessage)
-				return
+			if accountConfig.FileStoreConfig.Metadata {
+				if err := ws.metadata.Write(ws.Workspace); err != nil {
+					log.Errorf(color.RedString("Unable to write the file store metadata to %s - %s", ws.Workspace, err))
+					return
+				}
 			}
-		}
-		log.Info(color.GreenString("Remote url set to git repo"))
-
-		// Push files to git repo
-		var branch string
-		if accountConfig.FileStoreConfig.Branch != "" {
-			branch = accountConfig.FileStoreConfig.Branch
-		} else {
-			log.Error(color.RedString("File Store branch is not set"))
-			return
-		}
-
-		// Check if branch exists
-		cmd = exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			log.Warnf(color.YellowString("Branch %s does not exist", branch))
-			log.Infof("Creating branch %s", branch)
-
-			// Create new branch
-			cmd = exec.Command("git", "checkout", "-b", branch)
-			cmd.Dir = "./filestore"
-			cmd.Stderr, cmd.Stdout = &stderr, &stderr
-
-			err = cmd.Run()
-			if err != nil {
-				log.Errorf(color.RedString("Unable to create branch %s - %s", branch, err))
+			if err := ws.fileSync.Save(removed); err != nil {
+				log.Errorf(color.RedString("Unable to save the file store sync to %s - %s", ws.Workspace, err))
 				return
 			}
-		}
-		log.Infof("Branch %s exists", branch)
-
-		cmd = exec.Command("git", "pull", "origin", branch, "--allow-unrelated-histories", "--no-ff")
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			errorMessage := stderr.String()
-			if !strings.Contains(errorMessage, "couldn't find remote ref") {
-				log.Errorf("Unable to pull from remote repo - Git Operations log:\n %s", errorMessage)
+			message := "Initial Filestore commit"
+			if len(ws.fileSync.Previous.Files) > 0 {
+				message = fmt.Sprintf("Sync Filestore: %d files changed, %d files deleted", ws.changedFiles, len(removed))
+			}
+			if !pushFileStore(log, boldCyan, api, accountConfig, ws.FileStoreTarget, backend, message, removed) {
+				// The files deleted from Harness are removed again by the next sync
+				if err := ws.fileSync.Restore(); err != nil {
+					log.Errorf(color.RedString("Unable to restore the file store sync of %s - %s", ws.Workspace, err))
+				}
 				return
 			}
 		}
-
-		// Push files to git repo
-		cmd = exec.Command("git", "push", "origin", branch)
-		cmd.Dir = "./filestore"
-		cmd.Stderr, cmd.Stdout = &stderr, &stderr
-		err = cmd.Run()
-		if err != nil {
-			log.Errorf(color.RedString("Unable to push files to git repo - Git Operations log:\n %s", err))
-			return
+		if rewritesRefs {
+			moveEntities()
 		}
-		log.Info(color.GreenString("Files pushed to git repo!"))
 
 		if scope.ServiceManifests {
-			var targetServices, excludeServices []map[string]string
-			targetServices = accountConfig.TargetServices
-			excludeServices = accountConfig.ExcludeServices
-
 			log.Infof(boldCyan.Sprintf("---Getting Connector Info---"))
-			conn, err := api.GetConnector(
-				accountConfig.AccountIdentifier,
-				accountConfig.FileStoreConfig.Organization,
-				accountConfig.FileStoreConfig.Project,
-				accountConfig.GitDetails.ConnectorRef,
-			)
+			store, err := newGitStore(api, accountConfig, downloaded, scope.ForceUpdateManifests)
 			if err != nil {
 				log.Errorf("Unable to get Connector info - %s", err)
 				return
@@ -682,112 +653,42 @@ func main() {
 				p := project.Project
 				log.Infof(boldCyan.Sprintf("---Processing project %s!---", p.Name))
 				service, err := api.GetServices(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+				err = warnIfTruncated(log, err)
 				if err != nil {
 					log.Errorf(color.RedString("Unable to get service - %s", err))
 				}
-				if len(targetServices) > 0 {
-					var targeted []*harness.ServiceClass
-					for _, s := range service {
-						for _, t := range targetServices {
-							if value, exists := t[s.Identifier]; exists && value == s.Project {
-								log.Infof("Service [%s] in project [%s] is targeted for migration!", s.Name, s.Project)
-								targeted = append(targeted, s)
-							}
-						}
+				for _, s := range service {
+					if !accountConfig.ServiceSelected(s) {
+						log.Infof("Service [%s] in project [%s/%s] is not selected for migration, skipping!", s.Name, s.Org, s.Project)
+						continue
 					}
-					serviceList = append(serviceList, targeted...)
-				} else if len(excludeServices) > 0 {
-					var targeted []*harness.ServiceClass
-					for _, s := range service {
-						for _, t := range targetServices {
-							if value, exists := t[s.Identifier]; exists && value == s.Project {
-								log.Infof("Service [%s] in project [%s] is targeted for exclusion, skipping!", s.Name, s.Project)
-								continue
-							} else {
-								targeted = append(targeted, s)
-							}
-						}
+					if excluded(log, accountConfig, s.FilterSubject()) {
+						continue
 					}
-					serviceList = append(serviceList, targeted...)
-				} else {
-					serviceList = append(serviceList, service...)
+					if len(accountConfig.TargetServices) > 0 {
+						log.Infof("Service [%s] in project [%s/%s] is targeted for migration!", s.Name, s.Org, s.Project)
+					}
+					serviceList = append(serviceList, s)
 				}
 			}
 			log.Infof(color.BlueString("Found total of %d services", len(serviceList)))
 			log.Infof(boldCyan.Sprintf("---Processing Services---"))
 			serviceBar := pb.ProgressBarTemplate(serviceTmpl).Start(len(serviceList))
 			for _, service := range serviceList {
-				serviceYaml, err := service.ParseYAML()
+				updates, err := service.MoveManifestsToGit(store.In(service.Org, service.Project))
 				if err != nil {
 					log.Errorf(color.RedString("Unable to parse service YAML - %s", err))
+					failedServices = append(failedServices, service.Name)
+					serviceBar.Increment()
+					continue
 				}
-				var update = false
-				for i := range serviceYaml.Service.ServiceDefinition.Spec.Manifests {
-					m := &serviceYaml.Service.ServiceDefinition.Spec.Manifests[i]
-					if m.Manifest.Spec.Store.Type == "Harness" {
-						m.Manifest.Spec.Store.Type = harness.GetServiceManifestStoreType(conn.Type)
-						var files []string
-						for _, file := range m.Manifest.Spec.Store.Spec.Files {
-							files = append(files, fmt.Sprintf("filestore/%s/%s%s", service.Org, service.Project, file))
-						}
-						var valueFiles []string
-						if len(m.Manifest.Spec.ValuesPaths) > 0 {
-							log.Infof("Setting values file paths")
-							for _, v := range m.Manifest.Spec.ValuesPaths {
-								valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", service.Org, service.Project, v))
-							}
-							log.Infof("Setting following value file paths : %+v", valueFiles)
-						}
-						log.Infof("Setting following file paths : %+v", files)
-						if m.Manifest.Spec.Store.Type == "GitLab" || m.Manifest.Spec.Store.Type == "Github" {
-							m.Manifest.Spec.Store.Spec.Paths = files
-						} else {
-							m.Manifest.Spec.Store.Spec.Files = files
-						}
-						m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-						m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-						m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-						m.Manifest.Spec.ValuesPaths = valueFiles
-
-						update = true
-					} else if scope.ForceUpdateManifests {
-						m.Manifest.Spec.Store.Type = harness.GetServiceManifestStoreType(conn.Type)
-						var files []string
-						for _, file := range m.Manifest.Spec.Store.Spec.Files {
-							files = append(files, fmt.Sprintf("filestore/%s/%s%s", service.Org, service.Project, file))
-						}
-						var valueFiles []string
-						if len(m.Manifest.Spec.Store.ValuesPaths) > 0 {
-							for _, v := range m.Manifest.Spec.Store.ValuesPaths {
-								valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", service.Org, service.Project, v))
-							}
-						}
-						log.Infof("Setting following file paths : %+v", files)
-						m.Manifest.Spec.Store.Spec.Paths = files
-						m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-						m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-						m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-						m.Manifest.Spec.ValuesPaths = valueFiles
-
-						update = true
-					} else {
-						log.Infof("Manifest [%s] for Service [%s] is already remote!", m.Manifest.Identifier, service.Name)
-					}
-				}
-
-				if update {
-					// Marshal the modified ServiceYaml back to a YAML string
-					modifiedYAML, err := yaml.Marshal(serviceYaml)
-					if err != nil {
-						log.Errorf(color.RedString("Unable to marshal modified service YAML - %s", err))
-						failedServices = append(failedServices, service.Name)
-					} else {
-						service.YAML = string(modifiedYAML)
-					}
-
+				update := issues.record(log, updates, fmt.Sprintf("Service [%s]", service.Name))
+				if update && scope.DryRun {
+					log.Infof("Dry run: manifests of Service [%s] would be moved to Git", service.Name)
+				} else if update {
 					err = service.UpdateService(&api)
 					if err != nil {
-						log.Errorf(color.RedString("Unable to move service manifests - %s <%s>", service.Name, err, conn))
+						log.Errorf(color.RedString("Unable to move service manifests - %s <%s>", service.Name, err))
 						failedServices = append(failedServices, service.Name)
 					}
 				}
@@ -802,12 +703,7 @@ func main() {
 		if scope.Overrides {
 			log.Info(boldCyan.Sprintf("Processing Service overrides"))
 			log.Infof(boldCyan.Sprintf("---Getting Connector Info---"))
-			conn, err := api.GetConnector(
-				accountConfig.AccountIdentifier,
-				accountConfig.FileStoreConfig.Organization,
-				accountConfig.FileStoreConfig.Project,
-				accountConfig.GitDetails.ConnectorRef,
-			)
+			store, err := newGitStore(api, accountConfig, downloaded, scope.ForceUpdateManifests)
 			if err != nil {
 				log.Errorf("Unable to get Connector info - %s", err)
 				return
@@ -819,11 +715,16 @@ func main() {
 				log.Infof(boldCyan.Sprintf("---Fetching Overrides V2 ---"))
 				for _, ovType := range overrideTypes {
 					ov, err := api.GetOverridesV2(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, ovType)
+					err = warnIfTruncated(log, err)
 
 					if err != nil {
 						log.Errorf("Failed to get service overrides V2 type %s - %s", ovType, err)
-					} else {
-						overrides = append(overrides, ov...)
+						continue
+					}
+					for _, override := range ov {
+						if !excluded(log, accountConfig, override.FilterSubject()) {
+							overrides = append(overrides, override)
+						}
 					}
 				}
 
@@ -834,63 +735,23 @@ func main() {
 					pbBar := pb.ProgressBarTemplate(pbTemplate).Start(len(overrides))
 
 					for _, override := range overrides {
-						update := false
-						for i := range override.Spec.Manifests {
-							m := &override.Spec.Manifests[i]
-							if m.Manifest.Spec.Store.Type == "Harness" {
-
-								m.Manifest.Spec.Store.Type = conn.Type
-								var files []string
-								for _, file := range m.Manifest.Spec.Store.Spec.Files {
-									files = append(files, fmt.Sprintf("filestore/%s/%s%s", override.OrgIdentifier, override.ProjectIdentifier, file))
-								}
-								var valueFiles []string
-								if len(m.Manifest.Spec.ValuesPaths) > 0 {
-									for _, v := range m.Manifest.Spec.ValuesPaths {
-										valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", override.OrgIdentifier, override.ProjectIdentifier, v))
-									}
-								}
-								log.Infof("Setting following file paths : %+v", files)
-								m.Manifest.Spec.Store.Spec.Paths = files
-								m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-								m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-								m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-								m.Manifest.Spec.ValuesPaths = valueFiles
-
-								update = true
-							} else if scope.ForceUpdateManifests {
-								m.Manifest.Spec.Store.Type = conn.Type
-								var files []string
-								for _, file := range m.Manifest.Spec.Store.Spec.Files {
-									files = append(files, fmt.Sprintf("filestore/%s/%s%s", override.OrgIdentifier, override.ProjectIdentifier, file))
-								}
-								var valueFiles []string
-								if len(m.Manifest.Spec.ValuesPaths) > 0 {
-									for _, v := range m.Manifest.Spec.ValuesPaths {
-										valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", override.OrgIdentifier, override.ProjectIdentifier, v))
-									}
-								}
-								log.Infof("Setting following file paths : %+v", files)
-								m.Manifest.Spec.Store.Spec.Paths = files
-								m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-								m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-								m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-								m.Manifest.Spec.ValuesPaths = valueFiles
-
-								update = true
-							} else {
-								log.Infof("Override Manifest [%s] for Environment [%s] is already remote!", m.Manifest.Identifier, override.EnvironmentRef)
-							}
-							if update {
-								// Marshal the modified ServiceYaml back to a YAML string
-								log.Infof("Updating Override [%s]", override.Identifier)
-								override.YAML = ""
-								err := override.UpdateOverrideV2(&api, accountConfig.AccountIdentifier)
-
-								if err != nil {
-									log.Errorf(color.RedString("Unable to move service override manifests for environment [%s]", override.EnvironmentRef))
-									failedServices = append(failedServices, override.EnvironmentRef)
-								}
+						updates, err := override.MoveManifestsToGit(store.In(override.OrgIdentifier, override.ProjectIdentifier))
+						if err != nil {
+							log.Errorf(color.RedString("Unable to parse spec of Override [%s] - %s", override.Identifier, err))
+							failedServices = append(failedServices, override.EnvironmentRef)
+							pbBar.Increment()
+							continue
+						}
+						update := issues.record(log, updates, fmt.Sprintf("Override [%s]", override.Identifier))
+						if update && scope.DryRun {
+							log.Infof("Dry run: manifests of Override [%s] would be moved to Git", override.Identifier)
+						} else if update {
+							log.Infof("Updating Override [%s]", override.Identifier)
+							override.YAML = ""
+							err := override.UpdateOverrideV2(&api, accountConfig.AccountIdentifier)
+							if err != nil {
+								log.Errorf(color.RedString("Unable to move service override manifests for environment [%s]", override.EnvironmentRef))
+								failedServices = append(failedServices, override.EnvironmentRef)
 							}
 						}
 						pbBar.Increment()
@@ -904,12 +765,14 @@ func main() {
 
 			log.Info("Getting environments for Account level")
 			envs, err := api.GetEnvironments(accountConfig.AccountIdentifier, "", "")
+			err = warnIfTruncated(log, err)
 			if err != nil {
 				log.Errorf(color.RedString("Unable to get environments for account level. - %s", err))
 			}
 			environmentList = append(environmentList, envs...)
 
 			orgs, err := api.GetAllOrgs(accountConfig.AccountIdentifier)
+			err = warnIfTruncated(log, err)
 			if err != nil {
 				log.Errorf(color.RedString("Unable to get organizations for account %s - %s", accountConfig.AccountIdentifier, err))
 				return
@@ -919,6 +782,7 @@ func main() {
 				org := o.Org
 				log.Infof("Getting environements for organization [%s]", org.Identifier)
 				envs, err := api.GetEnvironments(accountConfig.AccountIdentifier, org.Identifier, "")
+				err = warnIfTruncated(log, err)
 				if err != nil {
 					log.Errorf(color.RedString("Unable to get environment for [%s] organization - %s", org.Name, err))
 					continue
@@ -930,6 +794,7 @@ func main() {
 			for _, project := range projectList {
 				p := project.Project
 				envs, err := api.GetEnvironments(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+				err = warnIfTruncated(log, err)
 				if err != nil {
 					log.Errorf(color.RedString("Unable to get environments for [%s] project. - %s", p.Name, err))
 				}
@@ -937,215 +802,1196 @@ func main() {
 			}
 
 			overridesBar := pb.ProgressBarTemplate(overridesTmpl).Start(len(environmentList))
-			var overrideList []*harness.ServiceOverrideContent
 			for _, env := range environmentList {
 				//Get All environment overrides
 				overrides, err := api.GetServiceOverrides(env.Identifier, accountConfig.AccountIdentifier, env.OrgIdentifier, env.ProjectIdentifier)
+				err = warnIfTruncated(log, err)
 				if err != nil {
 					log.Errorf("Unable to get service overrides for [%s] environment", env.Name)
 				}
-				overrideList = append(overrideList, overrides...)
-
-				for i := range overrideList {
-					overrideYaml, err := overrideList[i].ParseYAML()
+				for _, override := range overrides {
+					if excluded(log, accountConfig, override.FilterSubject()) {
+						continue
+					}
+					updates, err := override.MoveManifestsToGit(store.In(env.OrgIdentifier, env.ProjectIdentifier))
 					if err != nil {
 						log.Errorf(color.RedString("Unable to parse service override YAML - %s", err))
+						failedServices = append(failedServices, env.Name)
+						continue
 					}
-					var update = false
-					for i := range overrideYaml.ServiceOverrides.Manifests {
-						m := &overrideYaml.ServiceOverrides.Manifests[i]
-						if m.Manifest.Spec.Store.Type == "Harness" {
-							m.Manifest.Spec.Store.Type = conn.Type
-							var files []string
-							for _, file := range m.Manifest.Spec.Store.Spec.Files {
-								files = append(files, fmt.Sprintf("filestore/%s/%s%s", env.OrgIdentifier, env.ProjectIdentifier, file))
-							}
-							var valueFiles []string
-							if len(m.Manifest.Spec.ValuesPaths) > 0 {
-								for _, v := range m.Manifest.Spec.ValuesPaths {
-									valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", env.OrgIdentifier, env.ProjectIdentifier, v))
-								}
-							}
-							log.Infof("Setting following file paths : %+v", files)
-							m.Manifest.Spec.Store.Spec.Paths = files
-							m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-							m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-							m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-							m.Manifest.Spec.ValuesPaths = valueFiles
-
-							update = true
-						} else if scope.ForceUpdateManifests {
-							m.Manifest.Spec.Store.Type = conn.Type
-							var files []string
-							for _, file := range m.Manifest.Spec.Store.Spec.Files {
-								files = append(files, fmt.Sprintf("filestore/%s/%s%s", env.OrgIdentifier, env.ProjectIdentifier, file))
-							}
-							var valueFiles []string
-							if len(m.Manifest.Spec.ValuesPaths) > 0 {
-								for _, v := range m.Manifest.Spec.ValuesPaths {
-									valueFiles = append(valueFiles, fmt.Sprintf("filestore/%s/%s%s", env.OrgIdentifier, env.ProjectIdentifier, v))
-								}
-							}
-							log.Infof("Setting following file paths : %+v", files)
-							m.Manifest.Spec.Store.Spec.Paths = files
-							m.Manifest.Spec.Store.Spec.Branch = accountConfig.GitDetails.BranchName
-							m.Manifest.Spec.Store.Spec.ConnectorRef = accountConfig.GitDetails.ConnectorRef
-							m.Manifest.Spec.Store.Spec.GitFetchType = "Branch"
-							m.Manifest.Spec.ValuesPaths = valueFiles
-
-							update = true
-						} else {
-							log.Infof("ServiceOverride [%s] for Environment [%s] is already remote!", m.Manifest.Identifier, overrideList[i].EnvironmentRef)
-						}
-						if update {
-							// Marshal the modified ServiceYaml back to a YAML string
-							modifiedYAML, err := yaml.Marshal(overrideYaml)
-							if err != nil {
-								log.Errorf(color.RedString("Unable to marshal modified service override YAML - [%s]", err))
-								failedServices = append(failedServices, env.Name)
-							} else {
-								overrideList[i].YAML = string(modifiedYAML)
-							}
-
-							err = overrideList[i].UpdateEnvironment(&api)
-							if err != nil {
-								log.Errorf(color.RedString("Unable to move service override manifests for environment [%s]", env.Name))
-								failedServices = append(failedServices, env.Name)
-							}
+					update := issues.record(log, updates, fmt.Sprintf("Service [%s] in Environment [%s]", override.ServiceRef, env.Name))
+					if update && scope.DryRun {
+						log.Infof("Dry run: override manifests of Service [%s] in Environment [%s] would be moved to Git", override.ServiceRef, env.Name)
+					} else if update {
+						err = override.UpdateEnvironment(&api)
+						if err != nil {
+							log.Errorf(color.RedString("Unable to move service override manifests for environment [%s]", env.Name))
+							failedServices = append(failedServices, env.Name)
 						}
 					}
-					overridesBar.Increment()
 				}
+				overridesBar.Increment()
 			}
 			overridesBar.Finish()
 		}
+
+		issues.summary(log)
 	}
 }
 
-func processInfraDefScope(log *logrus.Logger, api harness.APIRequest, customGitDetailsFilePath string, accountConfig harness.Config, p harness.Project, gitX bool) error {
+// applyPlan executes an approved plan file. It re-plans the same entities
+// first and refuses to run when any of them changed since the plan was made.
+func applyPlan(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, scope MigrationScope, accountConfig harness.Config, path string, checkpoint *harness.Checkpoint, reportFiles string, verify bool) {
+	plan, err := harness.ReadPlanFile(path)
+	if err != nil {
+		log.Errorf(color.RedString("Unable to read plan file %s - %s", path, err))
+		return
+	}
+	if plan.AccountIdentifier != accountConfig.AccountIdentifier {
+		log.Errorf(color.RedString("Plan %s was made for account %s, not %s", path, plan.AccountIdentifier, accountConfig.AccountIdentifier))
+		return
+	}
+	log.Infof("Applying plan %s created at %s with %d entries", path, plan.CreatedAt.Format(time.RFC3339), len(plan.Entries))
+
+	// Only the entity types and projects of the plan are listed again
+	seen := map[string]bool{}
+	var projectList []harness.ProjectsContent
+	for _, e := range plan.Entries {
+		switch e.Type {
+		case harness.PipelineEntity:
+			scope.Pipelines = true
+		case harness.InputSetEntity:
+			scope.Inputsets = true
+		case harness.TemplateEntity:
+			scope.Templates = true
+		case harness.ServiceEntity:
+			scope.Services = true
+		case harness.EnvironmentEntity:
+			scope.Environments = true
+		case harness.InfrastructureEntity:
+			scope.InfraDef = true
+		case harness.OverridesV2Entity:
+			scope.OverridesV2 = true
+		}
+		if key := e.Org + "/" + e.Project; !seen[key] {
+			seen[key] = true
+			projectList = append(projectList, harness.ProjectsContent{Project: harness.Project{
+				OrgIdentifier: harness.OrgIdentifier(e.Org),
+				Identifier:    e.Project,
+				Name:          e.Project,
+			}})
+		}
+	}
 
-	projectEnvironments, err := api.GetEnvironments(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+	current, err := buildPlan(log, boldCyan, api, scope, accountConfig, projectList)
 	if err != nil {
-		log.Errorf(color.RedString("Unable to get environments - %s", err))
-		return err
+		return
+	}
+	// Entities moved by an interrupted apply are expected to be remote now
+	drift := plan.Drift(current, checkpoint)
+	if len(drift) > 0 {
+		for _, d := range drift {
+			log.Errorf(color.RedString(d))
+		}
+		log.Errorf(color.RedString("Refusing to apply plan %s, %d entities changed since it was made. Please create a new plan.", path, len(drift)))
+		return
 	}
 
-	if len(projectEnvironments) > 0 {
-		log.Infof("Moving infrastructures of %d environments to remote", len(projectEnvironments))
+	// Apply does not push the file store
+	refs, err := newFileStoreRefs(log, boldCyan, api, scope, accountConfig, projectList, false)
+	if err != nil {
+		return
+	}
 
-		pbTemplate := `{{ blue "Processing: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
-		pbBar := pb.ProgressBarTemplate(pbTemplate).Start(len(projectEnvironments))
+	report := harness.NewReport()
+	moved := executePlan(log, &api, accountConfig, plan, scope.Concurrency, checkpoint, report, refs)
+	migrationSummary(log, boldCyan, report)
+	if verify {
+		verifyEntries(log, boldCyan, &api, accountConfig, moved, scope.Concurrency, report)
+	}
+	writeReports(log, report, reportFiles)
+}
 
-		for _, environment := range projectEnvironments {
-			// ONLY TAKE CARE OF INFRA-DEF WHEN ENV IS REMOTE
-			if environment.StoreType == "REMOTE" {
-				infras, err := api.GetInfrastructures(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, environment.Identifier)
+// buildPlan lists the entities in scope for every project and adds them to a
+// new plan, in the order they are going to be migrated.
+func buildPlan(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, scope MigrationScope, accountConfig harness.Config, projectList []harness.ProjectsContent) (*harness.Plan, error) {
+	projectPlans := make([]*harness.Plan, len(projectList))
+	errs := make([]error, len(projectList))
+	harness.ForEach(projectList, scope.Concurrency, func(i int, project harness.ProjectsContent) {
+		projectPlans[i], errs[i] = planProject(log, boldCyan, api, scope, accountConfig, project.Project)
+	})
+
+	// Projects are merged in listing order whatever order they finished in
+	plan := harness.NewPlan(accountConfig.AccountIdentifier)
+	for i := range projectList {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		plan.Entries = append(plan.Entries, projectPlans[i].Entries...)
+	}
+	plan.Entries = harness.DependencyOrder(plan.Entries)
+
+	return plan, nil
+}
+
+// planProject lists the entities in scope for a single project, or for the
+// account or an org when p has no org or project identifier.
+func planProject(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, scope MigrationScope, accountConfig harness.Config, p harness.Project) (*harness.Plan, error) {
+	plan := &harness.Plan{}
+	log.Infof(boldCyan.Sprintf("---Processing %s!---", scopeLabel(p)))
+	// Pipelines are listed once for both pipelines and their input sets, they
+	// only exist in projects
+	var projectPipelines []harness.PipelineContent
+	if (scope.Pipelines || scope.Inputsets) && len(p.Identifier) > 0 {
+		log.Infof("Getting pipelines for project %s", p.Name)
+		result, err := api.GetAllPipelines(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+		err = warnIfTruncated(log, err)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to get pipelines - %s", err))
+			return nil, err
+		}
+		projectPipelines = result.Data.Content
+		log.Infof(color.BlueString("Found total of %d pipelines", len(projectPipelines)))
+	}
+	if scope.Pipelines {
+		for _, pipeline := range projectPipelines {
+			if excluded(log, accountConfig, pipeline.FilterSubject()) {
+				continue
+			}
+			entry := pipelineEntry(scope, accountConfig.GitRoute(p, pipeline.FilterSubject()), p, pipeline)
+			// The YAML is only needed to order the pipelines that are going to be moved
+			if pipeline.StoreType != harness.Remote {
+				pipelineYAML, err := api.GetPipelineYAML(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, pipeline.Identifier)
 				if err != nil {
-					log.Errorf(color.RedString("Unable to list infrastructure of environment - %s [%s]", environment.Identifier, err))
+					log.Warnf(color.YellowString("Unable to get YAML of pipeline %s, its dependencies are unknown - %s", pipeline.Identifier, err))
+				} else {
+					entry.References = harness.ExtractReferences(pipelineYAML, string(p.OrgIdentifier), p.Identifier)
+				}
+			}
+			plan.Add(entry)
+		}
+	}
+	if scope.Inputsets {
+		log.Infof("Getting inputsets for project %s", p.Name)
+		for _, pipeline := range projectPipelines {
+			// Input sets can only be moved once their pipeline is remote
+			if pipeline.StoreType != harness.Remote && (!scope.Pipelines || !accountConfig.Filters.Allows(pipeline.FilterSubject())) {
+				continue
+			}
+
+			inputsets, err := api.GetInputsets(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, pipeline.Identifier)
+			err = warnIfTruncated(log, err)
+			if err != nil {
+				log.Errorf(color.RedString("Unable to list inputsets from pipeline - %s", pipeline.Name))
+				continue
+			}
+
+			for _, is := range inputsets {
+				if excluded(log, accountConfig, is.FilterSubject()) {
 					continue
 				}
+				git := accountConfig.GitRoute(p, is.FilterSubject())
+				git.FilePath = harness.GetInputsetFilePath(scope.GitX, scope.CustomRemotePath, p, is)
+				plan.Add(harness.PlanEntry{
+					Type:          harness.InputSetEntity,
+					Org:           string(p.OrgIdentifier),
+					Project:       p.Identifier,
+					Identifier:    is.Identifier,
+					Name:          is.Name,
+					Pipeline:      is.PipelineIdentifier,
+					StoreType:     is.StoreType,
+					Version:       is.Version,
+					LastUpdatedAt: is.LastUpdatedAt,
+					GitDetails:    git,
+				})
+			}
+		}
+	}
 
-				if len(infras) > 0 {
-					for _, infraDef := range infras {
-						if infraDef.StoreType != "REMOTE" {
-							accountConfig.GitDetails.FilePath = harness.GetInfrastructureFilePath(gitX, customGitDetailsFilePath, p, *environment, *infraDef)
+	if scope.Templates {
+		// Get all templates for the project
+		log.Infof("Getting templates for %s", scopeLabel(p))
+		projectTemplates, err := api.GetAllTemplates(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+		err = warnIfTruncated(log, err)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to get templates - %s", err))
+			return nil, err
+		}
+		log.Infof(color.BlueString("Found total of %d templates", len(projectTemplates)))
+		for _, template := range projectTemplates {
+			if excluded(log, accountConfig, template.FilterSubject()) {
+				continue
+			}
+			plan.Add(templateEntry(scope, accountConfig.GitRoute(p, template.FilterSubject()), p, template))
+		}
+	}
 
-							err = infraDef.MoveInfrastructureToRemote(&api, accountConfig, environment.Identifier)
-							if err != nil {
-								log.Errorf(color.RedString("Unable to move infrastruture [%s] from environment [%s] - %s", infraDef.Name, environment.Name, err))
-							}
-						}
-					}
+	if scope.Services {
+		log.Infof("Getting services for %s", scopeLabel(p))
+		projectServices, err := api.GetServices(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+		err = warnIfTruncated(log, err)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to get services - %s", err))
+			return nil, err
+		}
+
+		log.Infof(color.BlueString("Found total of %d services", len(projectServices)))
+		for _, service := range projectServices {
+			if excluded(log, accountConfig, service.FilterSubject()) {
+				continue
+			}
+			git := accountConfig.GitRoute(p, service.FilterSubject())
+			git.FilePath = harness.GetServiceFilePath(scope.GitX, scope.CustomRemotePath, p, *service)
+			plan.Add(harness.PlanEntry{
+				Type:       harness.ServiceEntity,
+				Org:        string(p.OrgIdentifier),
+				Project:    p.Identifier,
+				Identifier: service.Identifier,
+				Name:       service.Name,
+				StoreType:  service.StoreType,
+				Checksum:   harness.YAMLChecksum(service.YAML),
+				References: harness.ExtractReferences(service.YAML, string(p.OrgIdentifier), p.Identifier),
+				GitDetails: git,
+			})
+		}
+	}
+
+	var projectEnvironments []*harness.EnvironmentClass
+	if scope.Environments || scope.InfraDef {
+		log.Infof("Getting environments for %s", scopeLabel(p))
+		list, err := api.GetEnvironments(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier)
+		err = warnIfTruncated(log, err)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to get environments - %s", err))
+			return nil, err
+		}
+		projectEnvironments = list
+		log.Infof(color.BlueString("Found total of %d environments", len(projectEnvironments)))
+	}
+	if scope.Environments {
+		for _, environment := range projectEnvironments {
+			if excluded(log, accountConfig, environment.FilterSubject()) {
+				continue
+			}
+			git := accountConfig.GitRoute(p, environment.FilterSubject())
+			git.FilePath = harness.GetEnvironmentFilePath(scope.GitX, scope.CustomRemotePath, p, *environment)
+			plan.Add(harness.PlanEntry{
+				Type:       harness.EnvironmentEntity,
+				Org:        string(p.OrgIdentifier),
+				Project:    p.Identifier,
+				Identifier: environment.Identifier,
+				Name:       environment.Name,
+				StoreType:  environment.StoreType,
+				Checksum:   harness.YAMLChecksum(environment.YAML),
+				References: harness.ExtractReferences(environment.YAML, string(p.OrgIdentifier), p.Identifier),
+				GitDetails: git,
+			})
+		}
+	}
+
+	if scope.InfraDef {
+		planInfrastructures(log, api, scope, accountConfig, p, projectEnvironments, plan)
+	}
+
+	if scope.OverridesV2 {
+		planOverridesV2(log, api, scope, accountConfig, p, plan)
+	}
+
+	return plan, nil
+}
+
+// manifestIssues collects the file store references of manifests and config
+// files that could not be moved to git.
+type manifestIssues struct {
+	missing     []string
+	secrets     []string
+	unsupported []string
+	mixed       []string
+}
+
+// record logs the manifests and config files moved to git and reports whether
+// any of them was.
+func (i *manifestIssues) record(log *logrus.Logger, updates []harness.ManifestUpdate, owner string) bool {
+	update := false
+	for _, m := range updates {
+		owner := owner
+		if len(m.Location) > 0 {
+			owner = fmt.Sprintf("%s at %s", owner, m.Location)
+		}
+		switch {
+		case m.Moved:
+			log.Infof("Setting following file paths for %s [%s] of %s : %+v", m.Type, m.Identifier, owner, m.Paths)
+			update = true
+		case len(m.SecretFiles) > 0:
+			log.Warnf(color.YellowString("%s [%s] of %s has secret files %v that cannot be moved to Git, it stays in the file store", m.Type, m.Identifier, owner, m.SecretFiles))
+			i.secrets = append(i.secrets, fmt.Sprintf("%s [%s] of %s: %s", m.Type, m.Identifier, owner, strings.Join(m.SecretFiles, ", ")))
+		case m.Unsupported:
+			log.Warnf(color.YellowString("%s [%s] of %s reads files %v that cannot be read from Git, it stays in the file store", m.Type, m.Identifier, owner, m.Paths))
+			i.unsupported = append(i.unsupported, fmt.Sprintf("%s [%s] of %s: %s", m.Type, m.Identifier, owner, strings.Join(m.Paths, ", ")))
+		case m.MixedRepos:
+			log.Warnf(color.YellowString("%s [%s] of %s reads files %v that are pushed to different repos, it stays in the file store", m.Type, m.Identifier, owner, m.Paths))
+			i.mixed = append(i.mixed, fmt.Sprintf("%s [%s] of %s: %s", m.Type, m.Identifier, owner, strings.Join(m.Paths, ", ")))
+		default:
+			log.Infof("%s [%s] of %s is already remote!", m.Type, m.Identifier, owner)
+		}
+		for _, ref := range m.Missing {
+			log.Warnf(color.YellowString("%s [%s] of %s references file [%s] that was not downloaded", m.Type, m.Identifier, owner, ref))
+			i.missing = append(i.missing, fmt.Sprintf("%s: %s", owner, ref))
+		}
+	}
+	return update
+}
+
+func (i *manifestIssues) summary(log *logrus.Logger) {
+	if len(i.missing) > 0 {
+		log.Warnf(color.HiYellowString("These file store references (count:%d) point to files that were not downloaded: \n%s", len(i.missing), strings.Join(i.missing, ",\n")))
+	}
+	if len(i.secrets) > 0 {
+		log.Warnf(color.HiYellowString("These config files (count:%d) keep secret files and stay in the file store: \n%s", len(i.secrets), strings.Join(i.secrets, ",\n")))
+	}
+	if len(i.unsupported) > 0 {
+		log.Warnf(color.HiYellowString("These file store references (count:%d) cannot be read from Git and stay in the file store: \n%s", len(i.unsupported), strings.Join(i.unsupported, ",\n")))
+	}
+	if len(i.mixed) > 0 {
+		log.Warnf(color.HiYellowString("These manifests (count:%d) read files pushed to different repos and stay in the file store: \n%s", len(i.mixed), strings.Join(i.mixed, ",\n")))
+	}
+}
+
+// fileStoreRefs points the file store references of pipelines and templates
+// to the pushed file store repo before they are moved. It is safe for
+// concurrent use, a nil fileStoreRefs does nothing.
+type fileStoreRefs struct {
+	store      harness.GitStore
+	reportOnly bool
+
+	mu     sync.Mutex
+	issues manifestIssues
+}
+
+// newFileStoreRefs returns nil unless the scope rewrites or reports file store
+// references. References are checked against the files of the account, of
+// the orgs and of the projects of scopeList. They are only rewritten when
+// pushes is set, the run pushes the file store before moving the entities.
+func newFileStoreRefs(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, scope MigrationScope, cfg harness.Config, scopeList []harness.ProjectsContent, pushes bool) (*fileStoreRefs, error) {
+	if !scope.FileStoreRefs || (!scope.Pipelines && !scope.Templates) {
+		return nil, nil
+	}
+	reportOnly := scope.FileStoreRefsReportOnly || scope.DryRun
+	if !reportOnly && !pushes {
+		log.Warnf(color.YellowString("File store references are only reported, they are pointed to the file store repo when it is pushed in the same run with -filestore"))
+		reportOnly = true
+	}
+
+	log.Infof(boldCyan.Sprintf("---Getting Connector Info---"))
+	store, err := newGitStore(api, cfg, listFileIndex(log, api, cfg, scopeList), false)
+	if err != nil {
+		log.Errorf(color.RedString("Unable to get Connector info - %s", err))
+		return nil, err
+	}
+
+	return &fileStoreRefs{
+		store:      store,
+		reportOnly: reportOnly,
+	}, nil
+}
+
+// newGitStore returns the store manifests and file store references are
+// pointed to, the connector of gitDetails and the connector of every repo of
+// fileStoreConfig for the files of its scope.
+func newGitStore(api harness.APIRequest, cfg harness.Config, files harness.FileIndex, force bool) (harness.GitStore, error) {
+	conn, err := api.GetConnector(cfg.AccountIdentifier, cfg.FileStoreConfig.Organization, cfg.FileStoreConfig.Project, cfg.GitDetails.ConnectorRef)
+	if err != nil {
+		return harness.GitStore{}, err
+	}
+	store := harness.NewGitStore(conn, cfg.GitDetails, files, force)
+
+	for _, t := range cfg.FileStoreConfig.Targets()[1:] {
+		if len(t.ConnectorRef) == 0 {
+			return store, fmt.Errorf("repo of file store scope %s has no connector_ref to point manifests to", t.Scope)
+		}
+		conn, err := api.GetConnector(cfg.AccountIdentifier, cfg.FileStoreConfig.Organization, cfg.FileStoreConfig.Project, t.ConnectorRef)
+		if err != nil {
+			return store, err
+		}
+		store.Repos = append(store.Repos, harness.NewScopeStore(conn, t))
+	}
+	return store, nil
+}
+
+// listFileIndex lists the files of every org and project of scopeList, and of
+// the account when its files are migrated, that the filters select. It
+// returns nil when a file store cannot be listed, so references are not
+// reported as missing by mistake.
+func listFileIndex(log *logrus.Logger, api harness.APIRequest, cfg harness.Config, scopeList []harness.ProjectsContent) harness.FileIndex {
+	log.Infof("Listing file store to check file store references")
+	type fileScope struct{ org, project string }
+	var scopes []fileScope
+	if cfg.FileStoreConfig.Scope.AccountFiles {
+		scopes = append(scopes, fileScope{})
+	}
+	seen := map[fileScope]bool{{}: true}
+	for _, s := range scopeList {
+		org := string(s.Project.OrgIdentifier)
+		for _, fs := range []fileScope{{org: org}, {org: org, project: s.Project.Identifier}} {
+			if len(fs.org) > 0 && !seen[fs] {
+				seen[fs] = true
+				scopes = append(scopes, fs)
+			}
+		}
+	}
+
+	files := harness.FileIndex{}
+	for _, fs := range scopes {
+		var list []harness.FileStoreContent
+		var err error
+		switch {
+		case len(fs.org) == 0:
+			list, err = api.GetAllAccountFiles(cfg.AccountIdentifier)
+		case len(fs.project) == 0:
+			list, err = api.GetAllOrgFiles(cfg.AccountIdentifier, fs.org)
+		default:
+			list, err = api.GetAllProjectFiles(cfg.AccountIdentifier, fs.org, fs.project)
+		}
+		if err != nil {
+			log.Warnf(color.YellowString("Unable to list file store of [%s/%s], missing files are not reported - %s", fs.org, fs.project, err))
+			return nil
+		}
+		for _, f := range list {
+			if cfg.Filters.Allows(f.FilterSubject()) {
+				files.Add(fs.org, fs.project, f)
+			}
+		}
+	}
+	return files
+}
+
+// prepare points the file store references of a pipeline or template entry
+// to the file store repo, without updating the entity. It returns nil for
+// other entries.
+func (r *fileStoreRefs) prepare(api *harness.APIRequest, cfg harness.Config, e harness.PlanEntry) (*harness.RefsRewrite, error) {
+	if r == nil {
+		return nil, nil
+	}
+	rw, err := e.RewriteFileStoreRefs(api, cfg, r.store)
+	if err != nil {
+		return nil, fmt.Errorf("unable to rewrite file store references - %w", err)
+	}
+	return rw, nil
+}
+
+// committed returns the YAML of the entry committed to git when it differs
+// from the one stored in Harness.
+func (r *fileStoreRefs) committed(rw *harness.RefsRewrite) (string, bool) {
+	if r == nil || r.reportOnly || !rw.Changed() {
+		return "", false
+	}
+	return rw.YAML, true
+}
+
+// rewrite updates the entity with the prepared references, or only logs them
+// when reporting. It reports whether the entity was updated in Harness.
+func (r *fileStoreRefs) rewrite(log *logrus.Logger, api *harness.APIRequest, cfg harness.Config, e harness.PlanEntry, rw *harness.RefsRewrite) (bool, error) {
+	if r == nil || rw == nil {
+		return false, nil
+	}
+	if !r.reportOnly {
+		if err := e.UpdateFileStoreRefs(api, cfg, rw); err != nil {
+			return false, fmt.Errorf("unable to rewrite file store references - %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owner := fmt.Sprintf("%s [%s]", e.Type, e.Ref())
+	if r.reportOnly && len(rw.Updates) > 0 {
+		log.Infof("Report only: file store references of %s are left unchanged", owner)
+	}
+	return r.issues.record(log, rw.Updates, owner) && !r.reportOnly, nil
+}
+
+// scan reports the file store references of the pipelines and templates the
+// plan moves, without moving them.
+func (r *fileStoreRefs) scan(log *logrus.Logger, api *harness.APIRequest, cfg harness.Config, plan *harness.Plan) {
+	if r == nil {
+		return
+	}
+	for _, e := range plan.Entries {
+		if e.Action != harness.ActionMove {
+			continue
+		}
+		rw, err := r.prepare(api, cfg, e)
+		if err == nil {
+			_, err = r.rewrite(log, api, cfg, e, rw)
+		}
+		if err != nil {
+			log.Errorf(color.RedString("%s [%s] - %s", e.Type, e.Ref(), err))
+		}
+	}
+	r.summary(log)
+}
+
+func (r *fileStoreRefs) summary(log *logrus.Logger) {
+	if r == nil {
+		return
+	}
+	r.issues.summary(log)
+}
+
+// excluded reports whether the filters of the config leave the entity out of
+// the migration.
+func excluded(log *logrus.Logger, cfg harness.Config, s harness.FilterSubject) bool {
+	if cfg.Filters.Allows(s) {
+		return false
+	}
+	log.Infof("%s [%s] is excluded by filters, skipping", s.Type, s.Identifier)
+	return true
+}
+
+// scopeLabel names the account, org or project p stands for in logs.
+func scopeLabel(p harness.Project) string {
+	switch {
+	case len(p.OrgIdentifier) == 0:
+		return "account"
+	case len(p.Identifier) == 0:
+		return fmt.Sprintf("org %s", p.OrgIdentifier)
+	default:
+		return fmt.Sprintf("project %s", p.Name)
+	}
+}
+
+// migrationScopes returns the account, the orgs and the projects whose
+// entities are migrated, in that order. Orgs are the ones of the selected
+// projects when projects are targeted or excluded, and every org selected by
+// targetOrgs and excludeOrgs otherwise.
+func migrationScopes(log *logrus.Logger, api harness.APIRequest, scope MigrationScope, accountConfig harness.Config, projectList []harness.ProjectsContent) ([]harness.ProjectsContent, error) {
+	var scopes []harness.ProjectsContent
+	if scope.AccountLevel {
+		scopes = append(scopes, harness.ProjectsContent{})
+	}
+
+	if scope.OrgLevel {
+		var orgs []string
+		if accountConfig.SelectsProjects() {
+			seen := map[string]bool{}
+			for _, project := range projectList {
+				org := string(project.Project.OrgIdentifier)
+				if !seen[org] {
+					seen[org] = true
+					orgs = append(orgs, org)
 				}
 			}
-			pbBar.Increment()
+		} else {
+			log.Infof("Getting organizations for account %s", accountConfig.AccountIdentifier)
+			list, err := api.GetAllOrgs(accountConfig.AccountIdentifier)
+			err = warnIfTruncated(log, err)
+			if err != nil {
+				log.Errorf(color.RedString("Unable to get organizations - %s", err))
+				return nil, err
+			}
+			for _, o := range list {
+				if accountConfig.OrgSelected(o.Org.Identifier) {
+					orgs = append(orgs, o.Org.Identifier)
+				}
+			}
+		}
+		for _, org := range orgs {
+			scopes = append(scopes, harness.ProjectsContent{Project: harness.Project{OrgIdentifier: harness.OrgIdentifier(org), Name: org}})
 		}
-		pbBar.Finish()
 	}
 
-	return nil
+	if scope.ProjectLevel {
+		scopes = append(scopes, projectList...)
+	}
+
+	return scopes, nil
 }
 
-func processOverridesV2(log *logrus.Logger, api harness.APIRequest, customGitDetailsFilePath string, cfg harness.Config, p harness.Project, gitX bool) error {
+// planInfrastructures adds the infrastructures of remote environments, or of
+// environments moved in this run, to the plan.
+func planInfrastructures(log *logrus.Logger, api harness.APIRequest, scope MigrationScope, accountConfig harness.Config, p harness.Project, projectEnvironments []*harness.EnvironmentClass, plan *harness.Plan) {
+	log.Infof("Getting infrastructures of %d environments", len(projectEnvironments))
+	for _, environment := range projectEnvironments {
+		// ONLY TAKE CARE OF INFRA-DEF WHEN ENV IS REMOTE
+		if environment.StoreType != "REMOTE" && (!scope.Environments || !accountConfig.Filters.Allows(environment.FilterSubject())) {
+			continue
+		}
 
-	overrideTypes := []harness.OverridesV2Type{harness.OV2_Global, harness.OV2_Service, harness.OV2_Infra, harness.OV2_ServiceInfra}
-	var overrides []harness.OverridesV2Content
+		infras, err := api.GetInfrastructures(accountConfig.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, environment.Identifier)
+		err = warnIfTruncated(log, err)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to list infrastructure of environment - %s [%s]", environment.Identifier, err))
+			continue
+		}
 
-	for _, ovType := range overrideTypes {
-		ov, err := api.GetOverridesV2(cfg.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, ovType)
+		for _, infraDef := range infras {
+			if excluded(log, accountConfig, infraDef.FilterSubject()) {
+				continue
+			}
+			// Infrastructures are routed with the type of their environment
+			subject := infraDef.FilterSubject()
+			subject.EnvironmentType = environment.Type
+			git := accountConfig.GitRoute(p, subject)
+			git.FilePath = harness.GetInfrastructureFilePath(scope.GitX, scope.CustomRemotePath, p, *environment, *infraDef)
+			plan.Add(harness.PlanEntry{
+				Type:        harness.InfrastructureEntity,
+				Org:         string(p.OrgIdentifier),
+				Project:     p.Identifier,
+				Identifier:  infraDef.Identifier,
+				Name:        infraDef.Name,
+				Environment: environment.Identifier,
+				StoreType:   infraDef.StoreType,
+				Checksum:    harness.YAMLChecksum(infraDef.YAML),
+				References:  harness.ExtractReferences(infraDef.YAML, string(p.OrgIdentifier), p.Identifier),
+				GitDetails:  git,
+			})
+		}
+	}
+}
 
+func planOverridesV2(log *logrus.Logger, api harness.APIRequest, scope MigrationScope, cfg harness.Config, p harness.Project, plan *harness.Plan) {
+	overrideTypes := []harness.OverridesV2Type{harness.OV2_Global, harness.OV2_Service, harness.OV2_Infra, harness.OV2_ServiceInfra}
+	for _, ovType := range overrideTypes {
+		overrides, err := api.GetOverridesV2(cfg.AccountIdentifier, string(p.OrgIdentifier), p.Identifier, ovType)
+		err = warnIfTruncated(log, err)
 		if err != nil {
 			log.Errorf("Failed to get service overrides V2 type %s - %s", ovType, err)
+			continue
+		}
+
+		for _, override := range overrides {
+			if excluded(log, cfg, override.FilterSubject()) {
+				continue
+			}
+			git := cfg.GitRoute(p, override.FilterSubject())
+			git.FilePath = harness.GetOverridesV2FilePath(scope.GitX, scope.CustomRemotePath, p, override)
+			plan.Add(harness.PlanEntry{
+				Type:           harness.OverridesV2Entity,
+				Org:            string(p.OrgIdentifier),
+				Project:        p.Identifier,
+				Identifier:     override.Identifier,
+				Environment:    override.EnvironmentRef,
+				Service:        override.ServiceRef,
+				Infrastructure: override.InfraIdentifier,
+				OverrideType:   override.Type,
+				StoreType:      override.StoreType,
+				Checksum:       harness.YAMLChecksum(override.YAML),
+				GitDetails:     git,
+			})
+		}
+	}
+}
+
+func pipelineEntry(scope MigrationScope, git harness.GitDetails, p harness.Project, pipeline harness.PipelineContent) harness.PlanEntry {
+	// Set the directory to pipelines and use the identifier as file name
+	if scope.UrlEncoding {
+		git.FilePath = harness.GetURLEncodedFilePath("pipelines", p, pipeline.Identifier+".yaml")
+	} else {
+		if scope.CGFolderStructure {
+			git.FilePath = harness.GetCGFilePath("pipelines", p, pipeline.Identifier+".yaml")
 		} else {
-			overrides = append(overrides, ov...)
+			git.FilePath = harness.GetPipelineFilePath(scope.GitX, scope.CustomRemotePath, p, pipeline)
 		}
 	}
 
-	if len(overrides) > 0 {
-		log.Infof("Moving %d service overrides V2 to remote", len(overrides))
+	return harness.PlanEntry{
+		Type:          harness.PipelineEntity,
+		Org:           string(p.OrgIdentifier),
+		Project:       p.Identifier,
+		Identifier:    pipeline.Identifier,
+		Name:          pipeline.Name,
+		StoreType:     string(pipeline.StoreType),
+		Version:       pipeline.Version,
+		LastUpdatedAt: pipeline.LastUpdatedAt,
+		GitDetails:    git,
+	}
+}
 
-		pbTemplate := `{{ blue "Processing: " }} {{ bar . "<" "-" (cycle . "↖" "↗" "↘" "↙" ) "." ">"}} {{percent .}} `
-		pbBar := pb.ProgressBarTemplate(pbTemplate).Start(len(overrides))
+func templateEntry(scope MigrationScope, git harness.GitDetails, p harness.Project, template harness.Template) harness.PlanEntry {
+	// Set the directory to templates and use the identifier as file name
+	if scope.UrlEncoding {
+		git.FilePath = harness.GetURLEncodedFilePath("templates", p, template.Identifier+"-"+template.VersionLabel+".yaml")
+	} else {
+		if scope.CGFolderStructure {
+			git.FilePath = harness.GetCGFilePath("templates", p, template.Identifier+"-"+template.VersionLabel+".yaml")
+		} else {
+			git.FilePath = harness.GetTemplateFilePath(scope.GitX, scope.CustomRemotePath, p, template)
+		}
+	}
 
-		for _, override := range overrides {
-			if override.StoreType != "REMOTE" {
-				cfg.GitDetails.FilePath = harness.GetOverridesV2FilePath(gitX, customGitDetailsFilePath, p, override)
+	return harness.PlanEntry{
+		Type:          harness.TemplateEntity,
+		Org:           template.Org,
+		Project:       template.Project,
+		Identifier:    template.Identifier,
+		Name:          template.Name,
+		VersionLabel:  template.VersionLabel,
+		StoreType:     template.StoreType,
+		Version:       template.Version,
+		LastUpdatedAt: template.Updated,
+		GitDetails:    git,
+	}
+}
 
-				if err := override.MoveToRemote(&api, cfg); err != nil {
-					log.Errorf(color.RedString("Unable to move overrides V2 [%s] - %s", override, err))
-				}
+// executePlan moves every entry of the plan to remote and adds the outcome of
+// every entry to the report. Up to concurrency entries are moved at once, in
+// plan order for every git branch. Entries the checkpoint marks as completed
+// are skipped and every outcome is recorded. File store references of
+// pipelines and templates are rewritten with refs first, unless it is nil.
+func executePlan(log *logrus.Logger, api *harness.APIRequest, cfg harness.Config, plan *harness.Plan, concurrency int, checkpoint *harness.Checkpoint, report *harness.Report, refs *fileStoreRefs) []harness.PlanEntry {
+	var moves []harness.PlanEntry
+	for _, e := range plan.Entries {
+		if e.Action != harness.ActionMove {
+			log.Infof("%s [%s] is %s, skipping", e.Type, e.Ref(), e.Reason)
+			report.Add(harness.NewSkippedRecord(e, e.Reason))
+			continue
+		}
+		if checkpoint != nil && checkpoint.Completed(e) {
+			log.Infof("%s [%s] was completed by a previous run, skipping", e.Type, e.Ref())
+			report.Add(harness.NewSkippedRecord(e, "completed by a previous run"))
+			continue
+		}
+		moves = append(moves, e)
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	log.Infof("Moving %d entities to remote", len(moves))
+	levels, deps, cyclic := harness.DependencyLevels(moves)
+	if cyclic {
+		log.Warnf(color.YellowString("Some entities depend on each other in a cycle, they are moved last"))
+	}
+
+	branches := harness.NewBranchLocks()
+	errs := make([]error, len(moves))
+	bar := pb.ProgressBarTemplate(moveTmpl).Start(len(moves))
+	// Levels run one after the other, so every entity is moved after the
+	// entities it depends on and is skipped when one of them failed
+	for l, level := range levels {
+		workers := concurrency
+		if cyclic && l == len(levels)-1 {
+			// Entities of a cycle depend on each other within the level
+			workers = 1
+		}
+		harness.ForEach(level, workers, func(_ int, i int) {
+			moveEntry(log, api, cfg, moves, deps[i], i, branches, errs, checkpoint, report, refs)
+			bar.Increment()
+		})
+	}
+	bar.Finish()
+	refs.summary(log)
+
+	// Entities found remote were moved by a retried call or since the plan was
+	// made, they are verified too
+	var moved []harness.PlanEntry
+	for i, e := range moves {
+		if errs[i] == nil || errors.Is(errs[i], harness.ErrAlreadyRemote) {
+			moved = append(moved, e)
+		}
+	}
+	return moved
+}
+
+// moveEntry moves moves[i] unless one of its dependencies failed and stores
+// its outcome in errs[i].
+func moveEntry(log *logrus.Logger, api *harness.APIRequest, cfg harness.Config, moves []harness.PlanEntry, deps []int, i int, branches *harness.BranchLocks, errs []error, checkpoint *harness.Checkpoint, report *harness.Report, refs *fileStoreRefs) {
+	e := moves[i]
+	for _, d := range deps {
+		if errs[d] != nil && !errors.Is(errs[d], harness.ErrAlreadyRemote) {
+			reason := fmt.Sprintf("%s [%s] it depends on was not moved", moves[d].Type, moves[d].Ref())
+			errs[i] = fmt.Errorf("%w - %s", harness.ErrDependencyFailed, reason)
+			log.Warnf(color.YellowString("Skipping %s [%s], %s", e.Type, e.Ref(), reason))
+			report.Add(harness.NewSkippedRecord(e, reason))
+			return
+		}
+	}
+
+	start := time.Now()
+	// An entity whose YAML holds secrets the policy blocks, or whose
+	// references could not be rewritten, stays inline. Secrets are scanned in
+	// the YAML committed to git, before the references are rewritten in
+	// Harness, so a blocked entity is left unchanged.
+	var findings []harness.SecretFinding
+	rw, err := refs.prepare(api, cfg, e)
+	if err == nil {
+		if yaml, ok := refs.committed(rw); ok {
+			findings, err = cfg.SecretScan.ScanEntity(yaml)
+		} else {
+			findings, err = e.ScanSecrets(api, cfg)
+		}
+		if err == nil && len(findings) > 0 {
+			log.Warnf(color.YellowString("Secrets found in %s [%s] - %s", e.Type, e.Ref(), harness.SecretsError(findings)))
+		}
+	}
+	rewritten := false
+	if err == nil {
+		rewritten, err = refs.rewrite(log, api, cfg, e, rw)
+	}
+	if err == nil {
+		unlock := branches.Lock(e.GitDetails)
+		err = e.Move(api, cfg)
+		unlock()
+	}
+	duration := time.Since(start)
+	if rewritten && err != nil && !errors.Is(err, harness.ErrAlreadyRemote) {
+		log.Warnf(color.YellowString("File store references of %s [%s] were rewritten but it stays inline", e.Type, e.Ref()))
+	}
+
+	errs[i] = err
+	record := harness.NewReportRecord(e, err, duration)
+	record.Findings = findings
+	record.RefsRewritten = rewritten
+	report.Add(record)
+	if checkpoint != nil {
+		if cpErr := checkpoint.Record(e, err, rewritten); cpErr != nil {
+			log.Warnf(color.YellowString("Unable to record %s [%s] in checkpoint - %s", e.Type, e.Ref(), cpErr))
+		}
+	}
+	if err != nil && !errors.Is(err, harness.ErrAlreadyRemote) {
+		log.Errorf(color.RedString("Unable to move %s - %s", e.Type, e.Ref()))
+		log.Errorf(color.RedString(err.Error()))
+	}
+}
+
+// verifyEntries fetches every entity from the branch it was moved to and
+// reports the ones that are not stored where they were planned.
+func verifyEntries(log *logrus.Logger, boldCyan *color.Color, api *harness.APIRequest, cfg harness.Config, entries []harness.PlanEntry, concurrency int, report *harness.Report) {
+	if len(entries) == 0 {
+		return
+	}
+
+	log.Infof(boldCyan.Sprintf("---Verifying %d entities---", len(entries)))
+	failed := make([]bool, len(entries))
+	bar := pb.ProgressBarTemplate(verifyTmpl).Start(len(entries))
+	harness.ForEach(entries, concurrency, func(i int, e harness.PlanEntry) {
+		start := time.Now()
+		mismatches, err := e.Verify(api, cfg)
+		report.Add(harness.NewVerifyRecord(e, mismatches, err, time.Since(start)))
+		if err != nil {
+			log.Errorf(color.RedString("Unable to verify %s [%s] - %s", e.Type, e.Ref(), err))
+			failed[i] = true
+		} else if len(mismatches) > 0 {
+			log.Errorf(color.RedString("%s [%s] does not match the plan - %s", e.Type, e.Ref(), strings.Join(mismatches, "; ")))
+			failed[i] = true
+		}
+		bar.Increment()
+	})
+	bar.Finish()
+
+	mismatched := 0
+	for _, f := range failed {
+		if f {
+			mismatched++
+		}
+	}
+
+	if mismatched > 0 {
+		log.Warnf(color.HiYellowString("%d of %d entities failed verification", mismatched, len(entries)))
+	} else {
+		log.Infof(color.GreenString("Verified %d entities, all are stored in git as planned", len(entries)))
+	}
+}
+
+// rollbackCheckpoint moves every entity the checkpoint records as moved back
+// inline, dependents before the entities they reference. When fromReport is
+// set the entities the JSON report records as moved are rolled back instead,
+// and the checkpoint is left as it is.
+func rollbackCheckpoint(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, accountConfig harness.Config, path, fromReport string, reportFiles string, dryRun bool) {
+	var checkpoint *harness.Checkpoint
+	var entries []harness.PlanEntry
+	if len(fromReport) > 0 {
+		path = fromReport
+		moved, err := harness.ReadReportFile(path)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to read JSON report %s - %s", path, err))
+			return
+		}
+		if moved.Rollback {
+			log.Errorf(color.RedString("Report %s was written by rollback, its entities were already moved inline", path))
+			return
+		}
+		entries = moved.MovedEntries()
+	} else {
+		if _, err := os.Stat(path); err != nil {
+			log.Errorf(color.RedString("Unable to read checkpoint file %s - %s", path, err))
+			return
+		}
+		var err error
+		checkpoint, err = harness.OpenCheckpoint(path, true, false)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to open checkpoint file %s - %s", path, err))
+			return
+		}
+		defer checkpoint.Close()
+		entries = checkpoint.MovedEntries()
+	}
+	if len(entries) == 0 {
+		log.Infof(color.GreenString("%s has no moved entities to roll back", path))
+		return
+	}
+	entries = harness.RollbackOrder(entries)
+
+	log.Infof(boldCyan.Sprintf("---Rolling back %d entities---", len(entries)))
+	if dryRun {
+		for _, e := range entries {
+			log.Infof("Dry run: %s [%s] would be moved inline from repo [%s] branch [%s] path [%s]",
+				e.Type, e.Ref(), e.GitDetails.RepoName, e.GitDetails.BranchName, e.GitDetails.FilePath)
+		}
+		return
+	}
+
+	report := harness.NewReport()
+	report.Rollback = true
+	failed := 0
+	bar := pb.ProgressBarTemplate(rollbackTmpl).Start(len(entries))
+	for _, e := range entries {
+		start := time.Now()
+		err := e.Rollback(&api, accountConfig)
+		report.Add(harness.NewReportRecord(e, err, time.Since(start)))
+		if checkpoint != nil {
+			if cpErr := checkpoint.RecordRollback(e, err); cpErr != nil {
+				log.Warnf(color.YellowString("Unable to record %s [%s] in checkpoint - %s", e.Type, e.Ref(), cpErr))
 			}
-			pbBar.Increment()
 		}
-		pbBar.Finish()
+		if err != nil {
+			log.Errorf(color.RedString("Unable to move %s [%s] inline - %s", e.Type, e.Ref(), err))
+			failed++
+		}
+		bar.Increment()
 	}
+	bar.Finish()
 
-	return nil
+	if failed > 0 {
+		log.Warnf(color.HiYellowString("%d of %d entities failed to roll back, rerun rollback to retry them", failed, len(entries)))
+	} else {
+		log.Infof(color.GreenString("Moved %d entities back inline!", len(entries)))
+	}
+	writeReports(log, report, reportFiles)
 }
 
-func servicesSummary(log *logrus.Logger, boldCyan *color.Color, failedTemplates []string, failedServices []string, alreadyRemoteServices []string, services []*harness.ServiceClass) {
-	log.Infof(boldCyan.Sprintf("---Services---"))
-	if len(failedTemplates) > 0 {
-		log.Warnf(color.HiYellowString("These services (count:%d) failed while moving to remote: \n%s", len(failedServices), strings.Join(failedServices, ",\n")))
+// verifyPlan verifies every entity a plan file moves.
+func verifyPlan(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, accountConfig harness.Config, path string, reportFiles string, concurrency int) {
+	plan, err := harness.ReadPlanFile(path)
+	if err != nil {
+		log.Errorf(color.RedString("Unable to read plan file %s - %s", path, err))
+		return
 	}
-	if len(alreadyRemoteServices) > 0 {
-		log.Warnf(color.HiYellowString("These services (count:%d) already remote: \n%s", len(alreadyRemoteServices), strings.Join(alreadyRemoteServices, ",\n")))
+	if plan.AccountIdentifier != accountConfig.AccountIdentifier {
+		log.Errorf(color.RedString("Plan %s was made for account %s, not %s", path, plan.AccountIdentifier, accountConfig.AccountIdentifier))
+		return
 	}
-	log.Infof(color.GreenString("Processed total of %d services", len(services)))
-	log.Infof(color.GreenString("------"))
-	log.Infof(color.GreenString("Moved services to remote!"))
-	log.Infof(color.GreenString("------"))
+
+	var entries []harness.PlanEntry
+	for _, e := range plan.Entries {
+		if e.Action == harness.ActionMove {
+			entries = append(entries, e)
+		}
+	}
+
+	report := harness.NewReport()
+	verifyEntries(log, boldCyan, &api, accountConfig, entries, concurrency, report)
+	writeReports(log, report, reportFiles)
 }
 
-func templatesSummary(log *logrus.Logger, boldCyan *color.Color, failedTemplates []string, templates []harness.Template) {
-	log.Infof(boldCyan.Sprintf("---Templates---"))
-	if len(failedTemplates) > 0 {
-		log.Warnf(color.HiYellowString("These templates (count:%d) failed while moving to remote: \n%s", len(failedTemplates), strings.Join(failedTemplates, ",\n")))
+func printPlan(log *logrus.Logger, boldCyan *color.Color, plan *harness.Plan) {
+	log.Infof(boldCyan.Sprintf("---Migration Plan (dry run)---"))
+	for _, e := range plan.Entries {
+		if e.Action != harness.ActionMove {
+			log.Infof("[%s] %s %s - %s", e.Action, e.Type, e.Ref(), e.Reason)
+			continue
+		}
+		log.Infof("[%s] %s %s (%s) -> repo [%s] branch [%s] path [%s] connector [%s]",
+			e.Action, e.Type, e.Ref(), e.StoreType, e.GitDetails.RepoName, e.GitDetails.BranchName, e.GitDetails.FilePath, e.GitDetails.ConnectorRef)
+	}
+
+	for _, t := range entityTypes {
+		if count := plan.MoveCount(t); count > 0 {
+			log.Infof(color.GreenString("%d %s entities would be moved to remote", count, t))
+		}
 	}
-	log.Infof(color.GreenString("Processed total of %d templates", len(templates)))
-	log.Infof(color.GreenString("------"))
-	log.Infof(color.GreenString("Moved templates to remote!"))
-	log.Infof(color.GreenString("------"))
 }
 
-func pipelinesSummary(log *logrus.Logger, boldCyan *color.Color, failedPipelines []string, pipelines []harness.PipelineContent) {
-	log.Infof(boldCyan.Sprintf("---Pipelines---"))
-	if len(failedPipelines) > 0 {
-		log.Warnf(color.HiYellowString("These pipelines (count:%d) failed while moving to remote: \n%s", len(failedPipelines), strings.Join(failedPipelines, ",\n")))
+// pushFileStore commits the workspace of the target and pushes it to the
+// target branch. Failures are logged and reported as false.
+func pushFileStore(log *logrus.Logger, boldCyan *color.Color, api harness.APIRequest, accountConfig harness.Config, target harness.FileStoreTarget, backend harness.GitBackend, message string, removed []string) bool {
+	log.Infof(boldCyan.Sprintf("---Pushing File Store---"))
+
+	// Set remote url to git repo
+	var url string
+	if target.URL != "" {
+		url = target.URL
+		if !strings.Contains(url, ".git") {
+			url += ".git"
+		}
+	} else {
+		var err error
+		conn, err := api.GetConnector(
+			accountConfig.AccountIdentifier,
+			accountConfig.FileStoreConfig.Organization,
+			accountConfig.FileStoreConfig.Project,
+			accountConfig.GitDetails.ConnectorRef,
+		)
+		if err != nil {
+			log.Errorf(color.RedString("Unable to get connector - %s", err))
+			return false
+		}
+		url = conn.Spec.URL + ".git"
+	}
+
+	var branch string
+	if target.Branch != "" {
+		branch = target.Branch
+	} else {
+		log.Error(color.RedString("File Store branch is not set"))
+		return false
+	}
+
+	dir := target.Workspace
+	log.Infof("Pushing %s to branch %s of %s", dir, branch, url)
+	if err := backend.Push(dir, url, branch, message, removed); err != nil {
+		log.Errorf(color.RedString("Unable to push files to git repo - %s", err))
+		return false
+	}
+	log.Info(color.GreenString("Files pushed to git repo!"))
+
+	return true
+}
+
+// fileStoreWorkspace is the workspace of a repo the file store is pushed to,
+// with the sync and the metadata of its files.
+type fileStoreWorkspace struct {
+	harness.FileStoreTarget
+	fileSync     *harness.FileStoreSync
+	metadata     *harness.FileMetadataIndex
+	listed       bool
+	changedFiles int
+}
+
+// keep carries the previous sync of a file that is not downloaded, and lists
+// it in the metadata index when it stays in the repo.
+func (ws *fileStoreWorkspace) keep(org, project string, f harness.FileStoreContent) {
+	if ws.fileSync.Keep(org, project, f) {
+		ws.metadata.Add(org, project, f)
+	}
+}
+
+// fileStoreWorkspaces routes the file stores to the workspace of their
+// target repo. It is safe for concurrent use.
+type fileStoreWorkspaces struct {
+	cfg        harness.FileStoreConfig
+	mu         sync.Mutex
+	workspaces []*fileStoreWorkspace
+	byDir      map[string]*fileStoreWorkspace
+}
+
+func newFileStoreWorkspaces(cfg harness.FileStoreConfig, incremental bool) (*fileStoreWorkspaces, error) {
+	w := &fileStoreWorkspaces{cfg: cfg, byDir: map[string]*fileStoreWorkspace{}}
+	for _, target := range cfg.Targets() {
+		if _, ok := w.byDir[target.Workspace]; ok {
+			continue
+		}
+		fileSync, err := harness.NewFileStoreSync(target.Workspace, incremental)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the last file store sync of %s - %w", target.Workspace, err)
+		}
+		ws := &fileStoreWorkspace{FileStoreTarget: target, fileSync: fileSync, metadata: harness.NewFileMetadataIndex()}
+		w.workspaces = append(w.workspaces, ws)
+		w.byDir[target.Workspace] = ws
+	}
+	return w, nil
+}
+
+// of returns the workspace the files of the org and project file store are
+// downloaded to.
+func (w *fileStoreWorkspaces) of(org, project string) *fileStoreWorkspace {
+	return w.byDir[w.cfg.Target(org, project).Workspace]
+}
+
+// listed records that the org and project file store was listed, only the
+// workspaces of listed file stores are pushed.
+func (w *fileStoreWorkspaces) listed(org, project string) {
+	ws := w.of(org, project)
+	ws.fileSync.Listed(org, project)
+	ws.metadata.Listed(org, project)
+	w.mu.Lock()
+	ws.listed = true
+	w.mu.Unlock()
+}
+
+func (w *fileStoreWorkspaces) changed(ws *fileStoreWorkspace) {
+	w.mu.Lock()
+	ws.changedFiles++
+	w.mu.Unlock()
+}
+
+// used returns the workspaces of listed file stores, in the order of the
+// targets.
+func (w *fileStoreWorkspaces) used() []*fileStoreWorkspace {
+	var used []*fileStoreWorkspace
+	for _, ws := range w.workspaces {
+		if ws.listed {
+			used = append(used, ws)
+		}
+	}
+	return used
+}
+
+// migrationSummary logs the outcome of every entity type found in the report.
+func migrationSummary(log *logrus.Logger, boldCyan *color.Color, report *harness.Report) {
+	for _, t := range entityTypes {
+		records := report.Filter(t, "")
+		if len(records) == 0 {
+			continue
+		}
+		label := entityLabels[t]
+
+		log.Infof(boldCyan.Sprintf("---%s%s---", strings.ToUpper(label[:1]), label[1:]))
+		if failed := report.Filter(t, harness.StatusFailed); len(failed) > 0 {
+			log.Warnf(color.HiYellowString("These %s (count:%d) failed while moving to remote: \n%s", label, len(failed), recordNames(failed)))
+		}
+		if alreadyRemote := report.Filter(t, harness.StatusAlreadyRemote); len(alreadyRemote) > 0 {
+			log.Warnf(color.HiYellowString("These %s (count:%d) already remote: \n%s", label, len(alreadyRemote), recordNames(alreadyRemote)))
+		}
+		if blocked := report.Filter(t, harness.StatusBlocked); len(blocked) > 0 {
+			log.Warnf(color.HiYellowString("These %s (count:%d) were kept inline, secrets were found in their YAML: \n%s", label, len(blocked), recordNames(blocked)))
+		}
+		log.Infof(color.GreenString("Processed total of %d %s", len(records), label))
+		log.Infof(color.GreenString("------"))
+		log.Infof(color.GreenString("Moved %d %s to remote!", len(report.Filter(t, harness.StatusSuccess)), label))
+		log.Infof(color.GreenString("------"))
+	}
+}
+
+func recordNames(records []harness.ReportRecord) string {
+	names := make([]string, 0, len(records))
+	for _, r := range records {
+		names = append(names, fmt.Sprintf("%s/%s", r.Scope, r.Identifier))
+	}
+	return strings.Join(names, ",\n")
+}
+
+// writeReports writes the report to every comma separated path, the format
+// follows the file extension.
+func writeReports(log *logrus.Logger, report *harness.Report, paths string) {
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if len(path) == 0 {
+			continue
+		}
+		if err := report.WriteFile(path); err != nil {
+			log.Errorf(color.RedString("Unable to write report %s - %s", path, err))
+			continue
+		}
+		log.Infof(color.GreenString("Report written to %s", path))
 	}
-	log.Infof(color.GreenString("Processed total of %d pipelines", len(pipelines)))
-	log.Infof(color.GreenString("------"))
-	log.Infof(color.GreenString("Moved pipelines to remote!"))
-	log.Infof(color.GreenString("------"))
 }
 
-func environmentsSummary(log *logrus.Logger, boldCyan *color.Color, failedEnvs []string, envs []*harness.EnvironmentClass) {
-	log.Infof(boldCyan.Sprintf("---Environments---"))
-	if len(failedEnvs) > 0 {
-		log.Warnf(color.HiYellowString("These environments (count:%d) failed while moving to remote: \n%s", len(failedEnvs), strings.Join(failedEnvs, ",\n")))
+// warnIfTruncated logs listings that were cut short and clears their error,
+// the items fetched before the failing page are still migrated.
+func warnIfTruncated(log *logrus.Logger, err error) error {
+	if harness.IsListTruncated(err) {
+		log.Warnf(color.YellowString("Listing is incomplete - %s", err))
+		return nil
 	}
-	log.Infof(color.GreenString("Processed total of %d environments", len(envs)))
-	log.Infof(color.GreenString("------"))
-	log.Infof(color.GreenString("Moved environments to remote!"))
-	log.Infof(color.GreenString("------"))
+	return err
 }